@@ -0,0 +1,148 @@
+package zfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ZFSCapabilities reports which optional features are supported by the installed zfs and zpool commands.
+type ZFSCapabilities struct {
+	// RawSend is true when encrypted datasets can be sent raw (zfs send -w).
+	RawSend bool
+	// RedactedSend is true when redacted streams can be sent (zfs send --redact).
+	RedactedSend bool
+	// LargeBlocks is true when blocks larger than 128KiB can be sent (zfs send -L).
+	LargeBlocks bool
+	// EmbedData is true when embedded data records can be sent (zfs send -e).
+	EmbedData bool
+	// ResumeSend is true when an interrupted send can be resumed from a token (zfs send -t).
+	ResumeSend bool
+	// ResumableReceive is true when a receive can save its partial state (zfs receive -s).
+	ResumableReceive bool
+	// PoolWait is true when the zpool wait command is available.
+	PoolWait bool
+}
+
+// Capabilities probes the installed zfs and zpool commands by parsing their usage text,
+// so that callers can avoid flags which are not supported by older ZFS versions.
+func (z *zfs) Capabilities() (*ZFSCapabilities, error) {
+	send, err := z.usage("zfs", "send")
+	if err != nil {
+		return nil, err
+	}
+	receive, err := z.usage("zfs", "receive")
+	if err != nil {
+		return nil, err
+	}
+	zpool, err := z.usage("zpool")
+	if err != nil {
+		return nil, err
+	}
+	return &ZFSCapabilities{
+		RawSend:          usageHasFlag(send, "send", "w"),
+		RedactedSend:     usageHasFlag(send, "send", "-redact"),
+		LargeBlocks:      usageHasFlag(send, "send", "L"),
+		EmbedData:        usageHasFlag(send, "send", "e"),
+		ResumeSend:       usageHasFlag(send, "send", "t"),
+		ResumableReceive: usageHasFlag(receive, "receive", "s"),
+		PoolWait:         usageHasCommand(zpool, "wait"),
+	}, nil
+}
+
+// CheckSend returns an error wrapping ErrNotSupported if any of the send options is not supported.
+func (c *ZFSCapabilities) CheckSend(opts SendOptions) error {
+	for _, o := range []struct {
+		set, supported bool
+		flag           string
+	}{
+		{opts.Raw, c.RawSend, "-w"},
+		{opts.LargeBlocks, c.LargeBlocks, "-L"},
+		{opts.EmbedData, c.EmbedData, "-e"},
+		{opts.RedactBookmark != "", c.RedactedSend, "--redact"},
+	} {
+		if o.set && !o.supported {
+			return fmt.Errorf("zfs send %s: %w by the installed zfs version", o.flag, ErrNotSupported)
+		}
+	}
+	return nil
+}
+
+// Version returns the versions of the OpenZFS userland tools and of the kernel module,
+// as reported by `zfs version`, e.g. "2.1.5-1ubuntu6".
+// The kernel module version is empty when the module is not loaded.
+func (z *zfs) Version() (zfsVersion, kmodVersion string, err error) {
+	out, err := z.doOutput("version")
+	if err != nil {
+		return "", "", err
+	}
+	for _, l := range out {
+		if len(l) == 0 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l[0], "zfs-kmod-"):
+			kmodVersion = strings.TrimPrefix(l[0], "zfs-kmod-")
+		case strings.HasPrefix(l[0], "zfs-"):
+			zfsVersion = strings.TrimPrefix(l[0], "zfs-")
+		}
+	}
+	if zfsVersion == "" {
+		return "", "", errors.New("could not parse zfs version")
+	}
+	return zfsVersion, kmodVersion, nil
+}
+
+// usage runs the command without its required arguments and returns the usage text it prints.
+func (z *zfs) usage(cmd string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	_, err := z.run(nil, &stdout, cmd, args...)
+	if err == nil {
+		return stdout.String(), nil
+	}
+	var zErr *Error
+	if !errors.As(err, &zErr) || zErr.Stderr == "" {
+		return "", err
+	}
+	return stdout.String() + zErr.Stderr, nil
+}
+
+// usageFlags matches the flag groups of a usage line, e.g. "-DnPpRVvLecwhb" or "--redact".
+var usageFlags = regexp.MustCompile(`(?:^|[\s\[])-(-?[a-zA-Z][a-zA-Z-]*)`)
+
+// usageHasFlag reports whether one of the usage lines of the sub command accepts the flag.
+// Short flags are given as a single letter, long flags with their leading dash, e.g. "-redact".
+func usageHasFlag(usage, sub, flag string) bool {
+	for _, l := range strings.Split(usage, "\n") {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, sub+" ") {
+			continue
+		}
+		for _, m := range usageFlags.FindAllStringSubmatch(l, -1) {
+			group := m[1]
+			if strings.HasPrefix(flag, "-") {
+				if group == flag {
+					return true
+				}
+				continue
+			}
+			if !strings.HasPrefix(group, "-") && strings.Contains(group, flag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// usageHasCommand reports whether the usage text lists the sub command.
+func usageHasCommand(usage, sub string) bool {
+	for _, l := range strings.Split(usage, "\n") {
+		f := strings.Fields(l)
+		if len(f) > 0 && f[0] == sub {
+			return true
+		}
+	}
+	return false
+}
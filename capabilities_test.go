@@ -0,0 +1,136 @@
+package zfs
+
+import (
+	"errors"
+	"testing"
+)
+
+// errUsage is the error of zfs and zpool when printing their usage.
+var errUsage = errors.New("exit status 2")
+
+const (
+	sendUsage = `missing snapshot argument
+usage:
+	send [-DLPbcehnpsVvw] [-i|-I snapshot]
+	     [-R [-X dataset[,dataset]...]]     <snapshot>
+	send [-DnVvPLecw] [-i snapshot|bookmark] <filesystem|volume|snapshot>
+	send [-DnPpVvLec] [-i bookmark|snapshot] --redact <bookmark> <snapshot>
+	send [-nVvPe] -t <receive_resume_token>
+	send [-PnVv] --saved filesystem
+`
+	receiveUsage = `missing snapshot argument
+usage:
+	receive [-vMnsFhu] [-o <property>=<value>] ... [-x <property>] ...
+	    <filesystem|volume|snapshot>
+	receive -A <filesystem|volume>
+`
+	zpoolUsage = `usage: zpool command args ...
+where 'command' is one of the following:
+
+	version
+
+	create [-fnd] [-o property=value] ...
+	    [-O file-system-property=value] ...
+	    [-m mountpoint] [-R root] <pool> <vdev> ...
+	wait [-Hp] [-T d|u] [-t <activity>[,...]] <pool> [interval]
+`
+	oldSendUsage = `missing snapshot argument
+usage:
+	send [-DnPpRvLe] [-[iI] snapshot] <snapshot>
+	send [-Le] [-i snapshot|bookmark] <filesystem|volume|snapshot>
+`
+	oldReceiveUsage = `missing snapshot argument
+usage:
+	receive [-vnFu] <filesystem|volume|snapshot>
+`
+)
+
+func TestCapabilities(t *testing.T) {
+	for name, test := range map[string]struct {
+		usage map[string]string
+		want  ZFSCapabilities
+	}{
+		"recent": {
+			usage: map[string]string{"zfs send": sendUsage, "zfs receive": receiveUsage, "zpool": zpoolUsage},
+			want:  ZFSCapabilities{RawSend: true, RedactedSend: true, LargeBlocks: true, EmbedData: true, ResumeSend: true, ResumableReceive: true, PoolWait: true},
+		},
+		"old": {
+			usage: map[string]string{"zfs send": oldSendUsage, "zfs receive": oldReceiveUsage, "zpool": "usage: zpool command args ...\n\tcreate [-fnd] <pool> <vdev> ...\n"},
+			want:  ZFSCapabilities{LargeBlocks: true, EmbedData: true},
+		},
+		"legacy": {
+			usage: map[string]string{"zfs send": "usage:\n\tsend [-DnPpRv] [-[iI] snapshot] <snapshot>\n", "zfs receive": oldReceiveUsage, "zpool": "usage: zpool command args ...\n"},
+			want:  ZFSCapabilities{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			z := &zfs{exec: &recordExec{stderrs: test.usage, err: errUsage}, logger: &defaultLogger{}}
+			got, err := z.Capabilities()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *got != test.want {
+				t.Fatalf("wanted: %+v, got: %+v", test.want, *got)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesCommandNotFound(t *testing.T) {
+	z := &zfs{exec: &recordExec{err: errors.New("exec: not found")}, logger: &defaultLogger{}}
+	if _, err := z.Capabilities(); err == nil {
+		t.Fatal("expected error when the commands cannot be run")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	for name, test := range map[string]struct {
+		out  string
+		zfs  string
+		kmod string
+		err  bool
+	}{
+		"loaded": {
+			out:  "zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.1.5-1ubuntu6~22.04.1\n",
+			zfs:  "2.1.5-1ubuntu6~22.04.1",
+			kmod: "2.1.5-1ubuntu6~22.04.1",
+		},
+		"module not loaded": {
+			out: "zfs-2.2.2-1\n",
+			zfs: "2.2.2-1",
+		},
+		"unexpected": {
+			out: "unknown command 'version'\n",
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			z := &zfs{exec: &recordExec{stdout: test.out}, logger: &defaultLogger{}}
+			zfsVersion, kmodVersion, err := z.Version()
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if zfsVersion != test.zfs || kmodVersion != test.kmod {
+				t.Fatalf("wanted: %q %q, got: %q %q", test.zfs, test.kmod, zfsVersion, kmodVersion)
+			}
+		})
+	}
+}
+
+func TestCheckSend(t *testing.T) {
+	c := &ZFSCapabilities{LargeBlocks: true}
+	if err := c.CheckSend(SendOptions{LargeBlocks: true, Compressed: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, opts := range []SendOptions{{EmbedData: true}, {Raw: true}, {RedactBookmark: "test/fs#b"}} {
+		if err := c.CheckSend(opts); !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%+v: wanted: %v, got: %v", opts, ErrNotSupported, err)
+		}
+	}
+}
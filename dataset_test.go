@@ -0,0 +1,426 @@
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEachDataset(t *testing.T) {
+	var stream string
+	for _, name := range []string{"test/a", "test/b", "test/c"} {
+		stream += testDatasetLine(name, "/"+name)
+	}
+	// without the trailing newline, the last line must still be handled
+	z := &zfs{exec: &recordExec{stdout: strings.TrimSuffix(stream, "\n")}, logger: &defaultLogger{}}
+
+	var names []string
+	err := z.EachDataset("", func(ds *Dataset) error {
+		names = append(names, ds.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"test/a", "test/b", "test/c"}; !reflect.DeepEqual(want, names) {
+		t.Fatalf("wanted: %v, got: %v", want, names)
+	}
+
+	stop := errors.New("stop")
+	names = nil
+	err = z.EachDataset("", func(ds *Dataset) error {
+		names = append(names, ds.Name)
+		if len(names) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("wanted: %v, got: %v", stop, err)
+	}
+	if want := []string{"test/a", "test/b"}; !reflect.DeepEqual(want, names) {
+		t.Fatalf("wanted: %v, got: %v", want, names)
+	}
+}
+
+func TestGetPropertiesFiltered(t *testing.T) {
+	e := &recordExec{stdout: "compression\tzstd\tlocal\natime\toff\tinherited from test\ncom.foo:bar\tbaz\treceived\n"}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/fs"}
+
+	props, err := d.GetPropertiesFiltered(nil, []string{PropertySourceLocal, PropertySourceInherited, PropertySourceReceived})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantArgs := []string{"get", "-Hp", "-o", "property,value,source", "-s", "local,inherited,received", "all", "test/fs"}
+	if !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+	want := []Property{
+		{Name: "compression", Value: "zstd", Source: PropertySourceLocal},
+		{Name: "atime", Value: "off", Source: PropertySourceInherited, InheritedFrom: "test"},
+		{Name: "com.foo:bar", Value: "baz", Source: PropertySourceReceived},
+	}
+	if !reflect.DeepEqual(want, props) {
+		t.Fatalf("wanted: %+v, got: %+v", want, props)
+	}
+
+	if _, err := d.GetPropertiesFiltered([]string{"compression", "atime"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantArgs = []string{"get", "-Hp", "-o", "property,value,source", "compression,atime", "test/fs"}
+	if !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+}
+
+func TestExportApplyProperties(t *testing.T) {
+	e := &recordExec{stdout: "compression\tzstd\tlocal\ncasesensitivity\tinsensitive\tlocal\ncom.foo:bar\tbaz\tlocal\n"}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+	src := &Dataset{z: z, Name: "test/src"}
+
+	props, err := src.ExportProperties()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"compression": "zstd", "com.foo:bar": "baz"}
+	if !reflect.DeepEqual(want, props) {
+		t.Fatalf("wanted: %v, got: %v", want, props)
+	}
+
+	dst := &Dataset{z: z, Name: "test/dst", props: make(map[string]string)}
+	if err := dst.ApplyProperties(props); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantArgs := []string{"set", "com.foo:bar=baz", "compression=zstd", "test/dst"}
+	if !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+	if v, _ := dst.GetProperty("compression"); v != "zstd" {
+		t.Fatalf("wanted the applied value to be cached, got: %s", v)
+	}
+}
+
+func TestDependentClones(t *testing.T) {
+	e := &recordExec{stdout: "destroy\ttest/fs/child@snap\n" +
+		"destroy\ttest/fs/child\n" +
+		"destroy\ttest/other/clone@snap\n" +
+		"destroy\ttest/other/clone\n" +
+		"destroy\ttest/fs@snap\n" +
+		"destroy\ttest/fs\n" +
+		"reclaim\t4096\n"}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/fs", Type: DatasetFilesystem}
+
+	plan, err := d.DestroyPlan(DestroyRecursiveClones)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wantArgs := []string{"destroy", "-nvp", "-R", "test/fs"}; !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+	if len(plan.Datasets) != 6 || plan.Reclaim != 4096 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	clones, err := d.DependentClones()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"test/other/clone"}; !reflect.DeepEqual(want, clones) {
+		t.Fatalf("wanted: %v, got: %v", want, clones)
+	}
+}
+
+func TestDestroyFlagValidate(t *testing.T) {
+	for name, test := range map[string]struct {
+		typ   string
+		flags DestroyFlag
+		err   bool
+	}{
+		"recursive":               {typ: DatasetFilesystem, flags: DestroyRecursive | DestroyForceUmount},
+		"recursive clones":        {typ: DatasetFilesystem, flags: DestroyRecursiveClones},
+		"both recursive":          {typ: DatasetFilesystem, flags: DestroyRecursive | DestroyRecursiveClones, err: true},
+		"defer snapshot":          {typ: DatasetSnapshot, flags: DestroyDeferDeletion | DestroyRecursive},
+		"defer filesystem":        {typ: DatasetFilesystem, flags: DestroyDeferDeletion, err: true},
+		"defer volume":            {typ: DatasetVolume, flags: DestroyDeferDeletion, err: true},
+		"both recursive snapshot": {typ: DatasetSnapshot, flags: DestroyRecursive | DestroyRecursiveClones, err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &recordExec{}
+			d := &Dataset{z: &zfs{exec: r, logger: &defaultLogger{}}, Name: "test/fs", Type: test.typ}
+			err := d.Destroy(test.flags)
+			if (err != nil) != test.err {
+				t.Fatalf("wanted error: %v, got: %v", test.err, err)
+			}
+			if test.err && r.args != nil {
+				t.Fatalf("the command should not have been run, got: %v", r.args)
+			}
+		})
+	}
+}
+
+func TestActualMountpoint(t *testing.T) {
+	e := &recordExec{outputs: map[string]string{
+		"zfs get -Hp -o value mounted,mountpoint test/my fs":  "yes\n/mnt/my data\n",
+		"zfs get -Hp -o value mounted,mountpoint test/off":    "no\n/test/off\n",
+		"zfs get -Hp -o value mounted,mountpoint test/my":     "yes\nlegacy\n",
+		"zfs get -Hp -o value mounted,mountpoint test/legacy": "yes\nlegacy\n",
+		"zfs mount": "test                            /test\n" +
+			"test/my fs                      /mnt/my data\n" +
+			"test/my                         /mnt/my legacy\n" +
+			"test/legacy fs                  /mnt/legacy\n",
+	}}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+
+	for name, want := range map[string]string{
+		"test/my fs": "/mnt/my data",
+		"test/off":   "",
+		"test/my":    "/mnt/my legacy",
+	} {
+		d := &Dataset{z: z, Name: name, Type: DatasetFilesystem}
+		got, err := d.ActualMountpoint()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("%s: wanted: %q, got: %q", name, want, got)
+		}
+	}
+	// test/legacy fs is listed, but not test/legacy
+	if _, err := (&Dataset{z: z, Name: "test/legacy", Type: DatasetFilesystem}).ActualMountpoint(); err == nil {
+		t.Fatal("expected an error when the legacy mountpoint is not found")
+	}
+}
+
+func TestMountWithOptions(t *testing.T) {
+	h := &recordExec{outputs: map[string]string{"zfs list": testDatasetLine("test/home", "/test/home")}}
+	z := &zfs{exec: h, logger: &defaultLogger{}}
+
+	d := &Dataset{z: z, Name: "test/home", Type: DatasetFilesystem, Encryption: "aes-256-gcm"}
+	if _, err := d.MountWithOptions(MountOptions{LoadKey: true, Overlay: true, Options: []string{"ro", "noatime"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "zfs mount -l -O -o ro,noatime test/home"; h.lines[0] != want {
+		t.Fatalf("wanted: %s, got: %s", want, h.lines[0])
+	}
+
+	h.lines = nil
+	d = &Dataset{z: z, Name: "test/plain", Type: DatasetFilesystem, Encryption: "off"}
+	if _, err := d.MountWithOptions(MountOptions{LoadKey: true}); err == nil {
+		t.Fatal("expected error loading the key of an unencrypted filesystem")
+	}
+	if len(h.lines) != 0 {
+		t.Fatalf("the command should not have been run, got: %v", h.lines)
+	}
+}
+
+func TestAutoSnapshot(t *testing.T) {
+	for value, want := range map[string]AutoSnapshot{
+		"true":  AutoSnapshotOn,
+		"FALSE": AutoSnapshotOff,
+		"-":     AutoSnapshotUnset,
+	} {
+		e := &recordExec{stdout: "test/fs\tcom.sun:auto-snapshot\t" + value + "\tinherited from test\n"}
+		d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/fs", props: make(map[string]string)}
+		state, err := d.AutoSnapshot()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if state != want {
+			t.Fatalf("%s: wanted: %v, got: %v", value, want, state)
+		}
+		enabled, _ := d.AutoSnapshotEnabled()
+		if enabled != (want != AutoSnapshotOff) {
+			t.Fatalf("%s: unexpected enabled: %v", value, enabled)
+		}
+	}
+
+	e := &recordExec{}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/fs", props: make(map[string]string)}
+	if err := d.SetAutoSnapshot(false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"set", "com.sun:auto-snapshot=false", "test/fs"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	if err := d.InheritAutoSnapshot(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"inherit", "com.sun:auto-snapshot", "test/fs"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+}
+
+func TestCloneOrigin(t *testing.T) {
+	h := &recordExec{outputs: map[string]string{
+		"zfs list test/clone":   testDatasetLine("test/clone", "-"),
+		"zfs list test/fs@snap": testDatasetLine("test/fs@snap", "-"),
+	}}
+	z := &zfs{exec: h, logger: &defaultLogger{}}
+	snap := &Dataset{z: z, Name: "test/fs@snap", Type: DatasetSnapshot}
+
+	c, err := snap.Clone("test/clone", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Origin != snap.Name {
+		t.Fatalf("wanted: %s, got: %s", snap.Name, c.Origin)
+	}
+	h.lines = nil
+	origin, err := c.OriginDataset()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if origin != snap || len(h.lines) != 0 {
+		t.Fatalf("wanted the cloned snapshot without a lookup, got: %v, %v", origin, h.lines)
+	}
+
+	// a listed clone looks its origin up
+	listed := &Dataset{z: z, Name: "test/clone", Type: DatasetFilesystem, Origin: "test/fs@snap"}
+	if origin, err = listed.OriginDataset(); err != nil || origin.Name != "test/fs@snap" || len(h.lines) != 1 {
+		t.Fatalf("unexpected origin: %v, %v, %v", origin, err, h.lines)
+	}
+	if origin, err = (&Dataset{z: z, Name: "test/fs"}).OriginDataset(); origin != nil || err != nil {
+		t.Fatalf("wanted no origin for a dataset which is not a clone, got: %v, %v", origin, err)
+	}
+}
+
+func TestDiffAgainst(t *testing.T) {
+	e := &recordExec{outputs: map[string]string{
+		"zfs get -Hp -o value origin test/clone":  "test/fs@base\n",
+		"zfs get -Hp -o value origin test/other":  "-\n",
+		"zfs diff -FH test/fs@base test/clone":    "M\t/\t/test/clone/\n+\tF\t/test/clone/file\n",
+		"zfs diff -FH test/fs@base test/fs@later": "+\tF\t/test/fs/file\n",
+		"zfs diff -FH test/fs@base test/other":    "",
+	}}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+
+	changes, err := z.DiffAgainst("test/fs@base", "test/clone")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("wanted 2 changes, got: %d", len(changes))
+	}
+
+	e.lines = nil
+	if _, err := z.DiffAgainst("test/fs@base", "test/fs@later"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"zfs diff -FH test/fs@base test/fs@later"}; !reflect.DeepEqual(want, e.lines) {
+		t.Fatalf("wanted: %v, got: %v", want, e.lines)
+	}
+
+	if _, err := z.DiffAgainst("test/fs@base", "test/other"); err == nil {
+		t.Fatal("expected error for an unrelated filesystem")
+	}
+	if _, err := z.DiffAgainst("test/fs", "test/clone"); err == nil {
+		t.Fatal("expected error for a filesystem instead of a snapshot")
+	}
+}
+
+func TestListSpace(t *testing.T) {
+	e := &recordExec{stdout: "test\t1000\t300\t100\t0\t100\t0\t200\ntest/vol\t1000\t200\t50\t10\t50\t140\t0\n"}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+
+	reports, err := z.ListSpace("test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantArgs := []string{"list", "-r", "-Hp", "-t", "filesystem,volume", "-o",
+		"name,available,used,referenced,usedbysnapshots,usedbydataset,usedbyrefreservation,usedbychildren", "test"}
+	if !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+	want := []SpaceReport{
+		{Name: "test", Avail: 1000, Used: 300, Referenced: 100, Usedbydataset: 100, Usedbychildren: 200},
+		{Name: "test/vol", Avail: 1000, Used: 200, Referenced: 50, Usedbysnapshots: 10, Usedbydataset: 50, Usedbyrefreservation: 140},
+	}
+	if !reflect.DeepEqual(want, reports) {
+		t.Fatalf("wanted: %+v, got: %+v", want, reports)
+	}
+}
+
+func TestWrittenSince(t *testing.T) {
+	e := &recordExec{stdout: "test/fs\twritten@snap\t4096\t-\n"}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/fs"}
+
+	written, err := d.WrittenSince("snap")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantArgs := []string{"get", "-H", "-p", "written@snap", "test/fs"}
+	if !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+	if written != 4096 {
+		t.Fatalf("wanted: 4096, got: %d", written)
+	}
+
+	e.args = nil
+	for _, snapshot := range []string{"", "test/fs@", "test/fs#"} {
+		if _, err := d.WrittenSince(snapshot); err == nil {
+			t.Fatalf("expected an error for snapshot %q", snapshot)
+		}
+	}
+	if e.args != nil {
+		t.Fatalf("expected no command, got: %v", e.args)
+	}
+}
+
+func TestSnapshotsBetween(t *testing.T) {
+	snapshotLine := func(name string, creation int64) string {
+		values := strings.Split(strings.TrimSuffix(testDatasetLine(name, "-"), "\n"), "\t")
+		for i, p := range dsPropList {
+			switch p {
+			case "type":
+				values[i] = DatasetSnapshot
+			case "creation":
+				values[i] = strconv.FormatInt(creation, 10)
+			}
+		}
+		return strings.Join(values, "\t") + "\n"
+	}
+	e := &recordExec{stdout: snapshotLine("test/fs@a", 100) + snapshotLine("test/fs@b", 200) + snapshotLine("test/fs@c", 200) + snapshotLine("test/fs@d", 300)}
+	fs := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/fs", Type: DatasetFilesystem}
+
+	names := func(snapshots []*Dataset) []string {
+		var n []string
+		for _, s := range snapshots {
+			n = append(n, s.Name)
+		}
+		return n
+	}
+	for name, test := range map[string]struct {
+		start, end time.Time
+		want       []string
+	}{
+		"window":    {start: time.Unix(150, 0), end: time.Unix(200, 0), want: []string{"test/fs@b", "test/fs@c"}},
+		"inclusive": {start: time.Unix(100, 0), end: time.Unix(300, 0), want: []string{"test/fs@a", "test/fs@b", "test/fs@c", "test/fs@d"}},
+		"open end":  {start: time.Unix(250, 0), want: []string{"test/fs@d"}},
+		"open":      {want: []string{"test/fs@a", "test/fs@b", "test/fs@c", "test/fs@d"}},
+		"empty":     {start: time.Unix(400, 0), want: nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			snapshots, err := fs.SnapshotsBetween(test.start, test.end)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := names(snapshots); !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+	wantArgs := []string{"list", "-d", "1", "-s", "createtxg", "-Hp", "-t", DatasetSnapshot, "-o", dsPropListOptions, "test/fs"}
+	if !reflect.DeepEqual(wantArgs, e.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, e.args)
+	}
+	if s, err := fs.SnapshotsBetween(time.Unix(0, 0), time.Unix(0, 0)); err != nil || len(s) != 0 {
+		t.Fatalf("unexpected result: %v, %v", s, err)
+	}
+	if _, err := fs.SnapshotsBetween(time.Unix(300, 0), time.Unix(100, 0)); err == nil {
+		t.Fatal("expected an error when start is after end")
+	}
+}
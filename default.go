@@ -1,6 +1,7 @@
 package zfs
 
 import (
+	"context"
 	"io"
 )
 
@@ -15,33 +16,78 @@ func SetDefault(zfs ZFS) {
 // SetLogger set a log handler to log all commands including arguments before they are executed.
 func SetLogger(l Logger) {
 	if z, ok := z.(*zfs); ok && l != nil {
-		z.logger = l
+		z.logger = newLockedLogger(l)
 	}
 }
 
+func WithContext(ctx context.Context) ZFS {
+	return z.WithContext(ctx)
+}
 func Datasets(filter string) ([]*Dataset, error) {
 	return z.Datasets(filter)
 }
+func EachDataset(filter string, fn func(*Dataset) error) error {
+	return z.EachDataset(filter, fn)
+}
+func ListSpace(filter string) ([]SpaceReport, error) {
+	return z.ListSpace(filter)
+}
+func List(filter string, opts ListOptions) ([]*Dataset, error) {
+	return z.List(filter, opts)
+}
 func Snapshots(filter string) ([]*Dataset, error) {
 	return z.Snapshots(filter)
 }
+func ListSnapshotsBrief(filter string) ([]SnapshotBrief, error) {
+	return z.ListSnapshotsBrief(filter)
+}
+func Bookmarks(filter string) ([]*Dataset, error) {
+	return z.Bookmarks(filter)
+}
 func Filesystems(filter string) ([]*Dataset, error) {
 	return z.Filesystems(filter)
 }
 func Volumes(filter string) ([]*Dataset, error) {
 	return z.Volumes(filter)
 }
+func ListFilesystems(parent string, recursive bool) ([]*Dataset, error) {
+	return z.ListFilesystems(parent, recursive)
+}
+func ListVolumes(parent string, recursive bool) ([]*Dataset, error) {
+	return z.ListVolumes(parent, recursive)
+}
 func GetDataset(name string) (*Dataset, error) {
 	return z.GetDataset(name)
 }
+func Exists(name string) (bool, error) {
+	return z.Exists(name)
+}
+func GetPropertyMany(datasets []string, prop string) (map[string]string, error) {
+	return z.GetPropertyMany(datasets, prop)
+}
 func ReceiveSnapshot(input io.Reader, name string, force ...bool) (*Dataset, error) {
 	return z.ReceiveSnapshot(input, name, force...)
 }
+func Receive(input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	return z.Receive(input, name, opts)
+}
+func ReceiveFromFile(path, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	return z.ReceiveFromFile(path, name, opts)
+}
+func ReceiveBackup(input io.Reader, name string) (*ReceiveResult, error) {
+	return z.ReceiveBackup(input, name)
+}
+func ResumeSend(token string, output io.Writer) error {
+	return z.ResumeSend(token, output)
+}
 func CreateVolume(name string, size uint64, properties map[string]string) (*Dataset, error) {
 	return z.CreateVolume(name, size, properties)
 }
-func CreateFilesystem(name string, properties map[string]string) (*Dataset, error) {
-	return z.CreateFilesystem(name, properties)
+func CreateFilesystem(name string, properties map[string]string, opts ...CreateFilesystemOptions) (*Dataset, error) {
+	return z.CreateFilesystem(name, properties, opts...)
+}
+func EnsureFilesystem(name string, properties map[string]string, reconcile bool) (*Dataset, error) {
+	return z.EnsureFilesystem(name, properties, reconcile)
 }
 func ListZpools() ([]*Zpool, error) {
 	return z.ListZpools()
@@ -52,3 +98,21 @@ func GetZpool(name string) (*Zpool, error) {
 func CreateZpool(name string, properties map[string]string, args ...string) (*Zpool, error) {
 	return z.CreateZpool(name, properties, args...)
 }
+func CreateZpoolWithVdevs(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) (*Zpool, error) {
+	return z.CreateZpoolWithVdevs(name, properties, vdevs, opts)
+}
+func PlanZpool(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) ([]VdevSpec, error) {
+	return z.PlanZpool(name, properties, vdevs, opts)
+}
+func LabelClear(device string, force bool) error {
+	return z.LabelClear(device, force)
+}
+func DiffAgainst(snapshot, other string) ([]*InodeChange, error) {
+	return z.DiffAgainst(snapshot, other)
+}
+func Capabilities() (*ZFSCapabilities, error) {
+	return z.Capabilities()
+}
+func Version() (zfsVersion, kmodVersion string, err error) {
+	return z.Version()
+}
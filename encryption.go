@@ -0,0 +1,81 @@
+package zfs
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ValidKeyLocation returns an error if the location is not a valid value of the keylocation property:
+// prompt, an absolute file:// URI, or an http:// or https:// URL of a key server.
+//
+// More information about the encryption properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html#keylocation
+func ValidKeyLocation(location string) error {
+	if location == "prompt" {
+		return nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("invalid key location %q: %w", location, err)
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+			return fmt.Errorf("invalid key location %q: the file path must be absolute, e.g. file:///path/to/key", location)
+		}
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("invalid key location %q: missing host", location)
+		}
+	default:
+		return fmt.Errorf("invalid key location %q: must be prompt, file://, http:// or https://", location)
+	}
+	return nil
+}
+
+// checkKeyLocation validates the keylocation property, if any, before creating a dataset.
+func checkKeyLocation(properties map[string]string) error {
+	if location, ok := properties["keylocation"]; ok {
+		return ValidKeyLocation(location)
+	}
+	return nil
+}
+
+// LoadKey loads the encryption key of the receiving encrypted dataset, so that it can be mounted.
+// The key is read from key if it is not nil, otherwise from the location set by the keylocation property,
+// e.g. a file or a key server.
+func (d *Dataset) LoadKey(key io.Reader) error {
+	args := []string{"load-key"}
+	if key != nil {
+		args = append(args, "-L", "prompt")
+	}
+	if _, err := d.z.run(key, nil, "zfs", append(args, d.Name)...); err != nil {
+		return err
+	}
+	d.KeyStatus = "available"
+	return nil
+}
+
+// LoadKeyFrom loads the encryption key of the receiving encrypted dataset from the given location
+// instead of the one set by the keylocation property, see ValidKeyLocation for the supported locations.
+func (d *Dataset) LoadKeyFrom(location string) error {
+	if err := ValidKeyLocation(location); err != nil {
+		return err
+	}
+	if err := d.z.do("load-key", "-L", location, d.Name); err != nil {
+		return err
+	}
+	d.KeyStatus = "available"
+	return nil
+}
+
+// UnloadKey unloads the encryption key of the receiving encrypted dataset, which must be unmounted first.
+func (d *Dataset) UnloadKey() error {
+	if err := d.z.do("unload-key", d.Name); err != nil {
+		return err
+	}
+	d.KeyStatus = "unavailable"
+	return nil
+}
@@ -0,0 +1,67 @@
+package zfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidKeyLocation(t *testing.T) {
+	for location, valid := range map[string]bool{
+		"prompt":                        true,
+		"file:///etc/zfs/keys/home.key": true,
+		"https://keys.example.com/home": true,
+		"http://10.0.0.1:8080/key":      true,
+		"file://etc/zfs/key":            false,
+		"file:relative/key":             false,
+		"https:///key":                  false,
+		"/etc/zfs/key":                  false,
+		"ftp://keys.example.com/key":    false,
+		"":                              false,
+	} {
+		if err := ValidKeyLocation(location); (err == nil) != valid {
+			t.Errorf("%q: wanted valid: %v, got: %v", location, valid, err)
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	e := &recordExec{}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/home", KeyStatus: "unavailable"}
+
+	if err := d.LoadKey(strings.NewReader("passphrase")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.LoadKey(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.LoadKeyFrom("https://keys.example.com/home"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.LoadKeyFrom("keys.example.com/home"); err == nil {
+		t.Fatal("expected error for an invalid location")
+	}
+	if err := d.UnloadKey(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"zfs load-key -L prompt test/home",
+		"zfs load-key test/home",
+		"zfs load-key -L https://keys.example.com/home test/home",
+		"zfs unload-key test/home",
+	}
+	if !reflect.DeepEqual(want, e.lines) {
+		t.Fatalf("wanted: %v, got: %v", want, e.lines)
+	}
+	if !reflect.DeepEqual([]string{"passphrase"}, e.stdins) {
+		t.Fatalf("unexpected keys: %v", e.stdins)
+	}
+	if d.KeyStatus != "unavailable" {
+		t.Fatalf("unexpected key status: %s", d.KeyStatus)
+	}
+
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+	if _, err := z.CreateFilesystem("test/enc", map[string]string{"encryption": "on", "keylocation": "file://key"}); err == nil {
+		t.Fatal("expected error for an invalid key location")
+	}
+}
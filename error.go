@@ -1,18 +1,81 @@
 package zfs
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ErrCommandTimeout is the error wrapped by Error when a command did not complete within the WithTimeout duration.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// The errors which may be matched, with errors.Is, against an Error to tell the cause of a command failure
+// from its stderr. The raw stderr remains available in the Error.
+var (
+	ErrDatasetNotFound  = errors.New("dataset does not exist")
+	ErrDatasetExists    = errors.New("dataset already exists")
+	ErrDatasetBusy      = errors.New("dataset is busy")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrPoolSuspended    = errors.New("pool I/O is suspended")
+	ErrOutOfSpace       = errors.New("out of space")
+	ErrReadOnly         = errors.New("read-only")
+	ErrNameTooLong      = errors.New("name is too long")
+	ErrInvalidName      = errors.New("invalid name")
+)
+
+// errorMessages are the lowercase zfs and zpool stderr messages which tell the cause of a failure.
+var errorMessages = map[error][]string{
+	ErrDatasetNotFound:  {"dataset does not exist"},
+	ErrDatasetExists:    {"dataset already exists"},
+	ErrDatasetBusy:      {"dataset is busy", "pool or dataset is busy"},
+	ErrPermissionDenied: {"permission denied", "must be superuser", "operation not permitted"},
+	ErrPoolSuspended:    {"pool i/o is currently suspended", "is suspended"},
+	ErrOutOfSpace:       {"out of space", "no space left on device"},
+	ErrReadOnly:         {"read-only"},
+	ErrNameTooLong:      {"name is too long"},
+	ErrInvalidName: {
+		"invalid character", "invalid dataset name", "invalid pool name", "invalid name", "empty component",
+		"leading slash", "trailing slash", "multiple '@'", "multiple '#'", "missing '@' delimiter",
+	},
+}
+
+// ErrNotSupported is the error wrapped when an option is not supported by the installed ZFS version or by a zpool.
+var ErrNotSupported = errors.New("not supported")
+
+// ErrBaseSnapshotMissing is the error wrapped when the base of an incremental send does not exist anymore.
+// Bookmarking the snapshots which are sent keeps incremental sends possible once they are destroyed,
+// see Dataset.Bookmark and Dataset.IncrementalSendFrom.
+var ErrBaseSnapshotMissing = errors.New("incremental base snapshot does not exist")
+
 // Error is an error which is returned when the `zfs` or `zpool` shell
 // commands return with a non-zero exit code.
 type Error struct {
 	Err    error
 	Debug  string
 	Stderr string
+	// Stdout is the output of the failed command, which may hold some context of the failure.
+	// It is empty when the output was written to a writer given by the caller, e.g. for a send.
+	Stdout string
 }
 
 // Error returns the string representation of an Error.
 func (e Error) Error() string {
 	return fmt.Sprintf("%s: %q => %s", e.Err, e.Debug, e.Stderr)
 }
+
+// Unwrap returns the underlying error.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the command failed because of the target error, as told by its stderr,
+// so that errors.Is(err, ErrDatasetNotFound) may be used on the errors returned by the commands.
+func (e Error) Is(target error) bool {
+	stderr := strings.ToLower(e.Stderr)
+	for _, m := range errorMessages[target] {
+		if strings.Contains(stderr, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -35,3 +35,66 @@ func TestError(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorIs(t *testing.T) {
+	var err error = &Error{
+		Err:    errors.New("exit status 1"),
+		Debug:  "zfs list test/missing",
+		Stderr: "cannot open 'test/missing': dataset does not exist\n",
+	}
+	if !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("wanted %v to match %v", err, ErrDatasetNotFound)
+	}
+	if errors.Is(&Error{Err: errors.New("exit status 1"), Stderr: "permission denied"}, ErrDatasetNotFound) {
+		t.Fatal("unexpected match of an unrelated error")
+	}
+	if !errors.Is(&Error{Err: ErrCommandTimeout}, ErrCommandTimeout) {
+		t.Fatal("the wrapped error should still match")
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	for stderr, want := range map[string]error{
+		"cannot create 'tank/fs': dataset already exists":                 ErrDatasetExists,
+		"cannot destroy 'tank/fs': dataset is busy":                       ErrDatasetBusy,
+		"cannot create 'tank/fs': permission denied":                      ErrPermissionDenied,
+		"cannot open 'tank': pool I/O is currently suspended":             ErrPoolSuspended,
+		"cannot create 'tank/fs': out of space":                           ErrOutOfSpace,
+		"cannot set property for 'tank/fs': dataset is read-only":         ErrReadOnly,
+		"cannot create 'tank/aaaa': name is too long":                     ErrNameTooLong,
+		"cannot create 'tank/f%s': invalid character '%' in name":         ErrInvalidName,
+		"cannot open 'tank/fs@': empty component or misplaced '@'":        ErrInvalidName,
+		"cannot open 'tank/missing': dataset does not exist":              ErrDatasetNotFound,
+		"internal error: Invalid argument\nzfs: unknown failure occurred": nil,
+	} {
+		err := &Error{Err: errors.New("exit status 1"), Stderr: stderr}
+		for _, target := range []error{
+			ErrDatasetNotFound, ErrDatasetExists, ErrDatasetBusy, ErrPermissionDenied, ErrPoolSuspended,
+			ErrOutOfSpace, ErrReadOnly, ErrNameTooLong, ErrInvalidName,
+		} {
+			if got := errors.Is(err, target); got != (target == want) {
+				t.Errorf("%q: errors.Is(%v): wanted: %v, got: %v", stderr, target, target == want, got)
+			}
+		}
+	}
+}
+
+func TestErrorStdout(t *testing.T) {
+	e := &recordExec{
+		stdout: "status: one or more devices has experienced an error\n",
+		stderr: "failure\n",
+		err:    errors.New("exit status 1"),
+	}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+	_, err := z.run(nil, nil, "zpool", "status", "test")
+	var zErr *Error
+	if !errors.As(err, &zErr) {
+		t.Fatalf("wanted an *Error, got: %v", err)
+	}
+	if zErr.Stdout != "status: one or more devices has experienced an error\n" {
+		t.Fatalf("unexpected stdout: %q", zErr.Stdout)
+	}
+	if zErr.Stderr != "failure\n" {
+		t.Fatalf("unexpected stderr: %q", zErr.Stderr)
+	}
+}
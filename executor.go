@@ -1,9 +1,17 @@
 package zfs
 
 import (
+	"context"
 	"io"
 )
 
 type Executor interface {
 	Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error
 }
+
+// ContextExecutor is an Executor which can stop a running command when its context is done.
+// It is used to enforce the WithTimeout option.
+type ContextExecutor interface {
+	Executor
+	RunContext(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error
+}
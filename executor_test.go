@@ -0,0 +1,120 @@
+package zfs
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// recordExec is a fake executor recording the commands it runs, which may run concurrently, and what they read from stdin.
+// Every command reads its stdin, or only its first limit bytes when limit is set, e.g. for an endless stream.
+// It then copies stream when it is set, and prints the output registered in outputs for its command line,
+// its sub command and last argument, e.g. "zfs list test/fs", its sub command, e.g. "zfs list", or its command,
+// in that order, or stdout otherwise, line by line as the commands do.
+// Once a command line registered in updates has run, its outputs replace the registered ones, e.g. to list a snapshot once taken.
+// The commands whose sub command is fail, or all of them if fail is empty, then print the stderr registered in stderrs
+// the same way, or stderr otherwise, and return err. When failures is set, they only fail that many times.
+type recordExec struct {
+	stdout   string
+	outputs  map[string]string
+	updates  map[string]map[string]string
+	stream   io.Reader
+	limit    int64
+	stderr   string
+	stderrs  map[string]string
+	err      error
+	fail     string
+	failures int
+
+	mu sync.Mutex
+	// cmd and args are the last command run.
+	cmd  string
+	args []string
+	// lines are the command lines run.
+	lines []string
+	// stdins are the inputs read by the commands.
+	stdins []string
+	// written is the number of output lines written successfully.
+	written int
+	// failed is the number of commands which failed.
+	failed int
+}
+
+// lookup returns the value registered in m for the command line, its sub command and last argument, its sub command or its command.
+func lookup(m map[string]string, cmd string, args []string) (string, bool) {
+	keys := []string{strings.Join(append([]string{cmd}, args...), " ")}
+	if len(args) > 0 {
+		keys = append(keys, cmd+" "+args[0]+" "+args[len(args)-1], cmd+" "+args[0])
+	}
+	for _, k := range append(keys, cmd) {
+		if v, ok := m[k]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (r *recordExec) Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
+	line := strings.Join(append([]string{cmd}, args...), " ")
+	r.mu.Lock()
+	r.cmd, r.args = cmd, args
+	r.lines = append(r.lines, line)
+	out, ok := lookup(r.outputs, cmd, args)
+	if !ok {
+		out = r.stdout
+	}
+	errOut, ok := lookup(r.stderrs, cmd, args)
+	if !ok {
+		errOut = r.stderr
+	}
+	failing := r.fail == "" || (len(args) > 0 && args[0] == r.fail)
+	if failing && r.failures > 0 {
+		failing = r.failed < r.failures
+	}
+	if failing && r.err != nil {
+		r.failed++
+	}
+	for k, v := range r.updates[line] {
+		if r.outputs == nil {
+			r.outputs = make(map[string]string)
+		}
+		r.outputs[k] = v
+	}
+	r.mu.Unlock()
+
+	if stdin != nil {
+		if r.limit > 0 {
+			stdin = io.LimitReader(stdin, r.limit)
+		}
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.stdins = append(r.stdins, string(b))
+		r.mu.Unlock()
+	}
+	if r.stream != nil {
+		if _, err := io.Copy(stdout, r.stream); err != nil {
+			return err
+		}
+	}
+	for _, l := range strings.SplitAfter(out, "\n") {
+		if l == "" {
+			continue
+		}
+		if _, err := io.WriteString(stdout, l); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.written++
+		r.mu.Unlock()
+	}
+	if !failing {
+		return nil
+	}
+	if errOut != "" {
+		io.WriteString(stderr, errOut)
+	}
+	return r.err
+}
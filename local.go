@@ -1,6 +1,7 @@
 package zfs
 
 import (
+	"context"
 	"io"
 	"os/exec"
 )
@@ -12,6 +13,12 @@ func NewLocalExecutor() Executor {
 type localExec struct{}
 
 func (l *localExec) Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
+	return l.RunContext(context.Background(), stdin, stdout, stderr, cmd, args...)
+}
+
+// RunContext runs the command, killing its whole process group when ctx is done
+// so that no descendant process outlives a cancelled command.
+func (l *localExec) RunContext(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
 	c := exec.Command(cmd, args...)
 	if stdin != nil {
 		c.Stdin = stdin
@@ -22,5 +29,24 @@ func (l *localExec) Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd
 	if stderr != nil {
 		c.Stderr = stderr
 	}
-	return c.Run()
+	// only detach the command from our process group when it may need to be killed,
+	// so that terminal signals keep reaching the non cancellable ones
+	if ctx.Done() != nil {
+		setProcessGroup(c)
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = killProcessGroup(c)
+		<-done
+		return ctx.Err()
+	}
 }
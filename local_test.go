@@ -0,0 +1,29 @@
+package zfs
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not found")
+	}
+	z, err := New(WithTimeout(100 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	_, err = z.(*zfs).run(nil, nil, "sleep", "10")
+	if !errors.Is(err, ErrCommandTimeout) {
+		t.Fatalf("expected ErrCommandTimeout, got: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("command was not stopped after the timeout: %s", d)
+	}
+	if _, err := z.(*zfs).run(nil, nil, "sleep", "0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package zfs
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(*exec.Cmd) {}
+
+func killProcessGroup(c *exec.Cmd) error {
+	return c.Process.Kill()
+}
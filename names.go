@@ -0,0 +1,119 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxDatasetNameLen is the maximum length of a ZFS dataset name, including its snapshot or bookmark part.
+const maxDatasetNameLen = 255
+
+// IsValidDatasetName reports whether name is a valid name of a filesystem, volume, snapshot or bookmark,
+// e.g. to check a user provided name before passing it to a command.
+// The name is made of components separated by '/', with an optional snapshot or bookmark part after a single '@' or '#'.
+// The components may only contain alphanumeric characters, '-', '_', '.', ':' and spaces,
+// and must not be empty, "." or "..". The first component, the zpool name, must begin with a letter.
+//
+// More information about the naming rules may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs.8.html#DESCRIPTION
+func IsValidDatasetName(name string) bool {
+	return checkDatasetName(name) == nil
+}
+
+// ValidDatasetName returns an error if name is not a valid filesystem or volume name, see IsValidDatasetName.
+func ValidDatasetName(name string) error {
+	if i := strings.IndexAny(name, "@#"); i >= 0 {
+		return fmt.Errorf("invalid dataset name %q: unexpected %q", name, name[i])
+	}
+	return checkDatasetName(name)
+}
+
+// ValidSnapshotName returns an error if name is not a valid full snapshot name, i.e. a filesystem or volume name
+// followed by a single '@' and the snapshot name, see IsValidDatasetName.
+func ValidSnapshotName(name string) error {
+	if !strings.Contains(name, "@") {
+		return fmt.Errorf("invalid snapshot name %q: missing '@'", name)
+	}
+	return checkDatasetName(name)
+}
+
+// reservedPoolNamePrefixes are the prefixes of the zpool names which would be mistaken for a vdev type.
+var reservedPoolNamePrefixes = []string{"mirror", "raidz", "draid", "spare"}
+
+// ValidPoolName returns an error if name is not a valid zpool name: it must begin with a letter,
+// may only contain alphanumeric characters, '-', '_', '.', ':' and spaces, must not be "log"
+// or begin with a vdev type such as mirror or raidz, and must not look like a disk name such as c0t0d0.
+//
+// More information about the naming rules may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-create.8.html
+func ValidPoolName(name string) error {
+	if err := checkNameComponent(name); err != nil {
+		return fmt.Errorf("invalid zpool name %q: %w", name, err)
+	}
+	if len(name) > maxDatasetNameLen {
+		return fmt.Errorf("zpool name %q is too long", name)
+	}
+	if !isLetter(name[0]) {
+		return fmt.Errorf("invalid zpool name %q: must begin with a letter", name)
+	}
+	if name == "log" {
+		return fmt.Errorf("invalid zpool name %q: reserved name", name)
+	}
+	for _, p := range reservedPoolNamePrefixes {
+		if strings.HasPrefix(name, p) {
+			return fmt.Errorf("invalid zpool name %q: names beginning with %q are reserved", name, p)
+		}
+	}
+	if len(name) > 1 && name[0] == 'c' && name[1] >= '0' && name[1] <= '9' {
+		return fmt.Errorf("invalid zpool name %q: reserved disk name", name)
+	}
+	return nil
+}
+
+// checkDatasetName returns an error describing why name is not a valid dataset name, see IsValidDatasetName.
+func checkDatasetName(name string) error {
+	if name == "" {
+		return errors.New("dataset name must not be empty")
+	}
+	if len(name) > maxDatasetNameLen {
+		return fmt.Errorf("dataset name %q is too long", name)
+	}
+	fs := name
+	if i := strings.IndexAny(name, "@#"); i >= 0 {
+		fs = name[:i]
+		if err := checkNameComponent(name[i+1:]); err != nil {
+			return fmt.Errorf("invalid dataset name %q: %w", name, err)
+		}
+	}
+	for _, c := range strings.Split(fs, "/") {
+		if err := checkNameComponent(c); err != nil {
+			return fmt.Errorf("invalid dataset name %q: %w", name, err)
+		}
+	}
+	if !isLetter(name[0]) {
+		return fmt.Errorf("invalid dataset name %q: the zpool name must begin with a letter", name)
+	}
+	return nil
+}
+
+// checkNameComponent returns an error if c is not a valid component of a dataset name,
+// i.e. a filesystem name between '/', or a snapshot or bookmark name after the '@' or '#'.
+func checkNameComponent(c string) error {
+	switch c {
+	case "":
+		return errors.New("empty component")
+	case ".", "..":
+		return fmt.Errorf("reserved component %q", c)
+	}
+	for _, r := range c {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && !strings.ContainsRune("-_.: ", r) {
+			return fmt.Errorf("invalid character %q", r)
+		}
+	}
+	return nil
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
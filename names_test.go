@@ -0,0 +1,123 @@
+package zfs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsValidDatasetName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"test":                             true,
+		"test/fs":                          true,
+		"test/my fs":                       true,
+		"test/fs-1_a.b:c":                  true,
+		"test/fs@snap":                     true,
+		"test/fs#bookmark":                 true,
+		"":                                 false,
+		"test/":                            false,
+		"/test":                            false,
+		"test//fs":                         false,
+		"test/./fs":                        false,
+		"test/..":                          false,
+		"test/fs@":                         false,
+		"test/fs@a@b":                      false,
+		"test/fs@a#b":                      false,
+		"test/fs@snap/child":               false,
+		"test/fs@a,b":                      false,
+		"test/fs;rm":                       false,
+		"test/$(id)":                       false,
+		"test/fs\n":                        false,
+		"test/" + strings.Repeat("a", 251): false,
+	} {
+		if got := IsValidDatasetName(name); got != valid {
+			t.Errorf("%q: wanted: %v, got: %v", name, valid, got)
+		}
+	}
+}
+
+func TestSnapshotNameValidation(t *testing.T) {
+	e := &recordExec{}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+	fs := &Dataset{z: z, Name: "test/fs", Type: DatasetFilesystem}
+	snap := &Dataset{z: z, Name: "test/fs@snap", Type: DatasetSnapshot}
+
+	if err := fs.DestroySnapshots([]string{"a", "b,c"}, DestroyDefault); err == nil {
+		t.Fatal("expected an error destroying snapshot b,c")
+	}
+	if err := fs.DestroySnapshotRange("a", "b%c", DestroyDefault); err == nil {
+		t.Fatal("expected an error destroying an invalid snapshot range")
+	}
+	if _, err := snap.Bookmark("b#c"); err == nil {
+		t.Fatal("expected an error creating bookmark b#c")
+	}
+	if _, err := snap.Redact("", snap); err == nil {
+		t.Fatal("expected an error creating an empty redaction bookmark")
+	}
+	if _, err := snap.RenameSnapshot("..", false); err == nil {
+		t.Fatal("expected an error renaming snapshot to ..")
+	}
+	if e.cmd != "" {
+		t.Fatalf("expected no command, got: %s %v", e.cmd, e.args)
+	}
+}
+
+func TestValidNames(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		validate func(string) error
+		valid    bool
+	}{
+		{"test/fs", ValidDatasetName, true},
+		{"test/fs@snap", ValidDatasetName, false},
+		{"test/fs#bookmark", ValidDatasetName, false},
+		{"1test/fs", ValidDatasetName, false},
+		{"test/fs@snap", ValidSnapshotName, true},
+		{"test/fs", ValidSnapshotName, false},
+		{"test/fs#bookmark", ValidSnapshotName, false},
+		{"test/fs@a@b", ValidSnapshotName, false},
+		{"test", ValidPoolName, true},
+		{"tank-1", ValidPoolName, true},
+		{"", ValidPoolName, false},
+		{"test/fs", ValidPoolName, false},
+		{"1test", ValidPoolName, false},
+		{"log", ValidPoolName, false},
+		{"mirror0", ValidPoolName, false},
+		{"raidz", ValidPoolName, false},
+		{"draid2", ValidPoolName, false},
+		{"spares", ValidPoolName, false},
+		{"c0t0d0", ValidPoolName, false},
+	} {
+		if err := test.validate(test.name); (err == nil) != test.valid {
+			t.Errorf("%q: wanted valid: %v, got: %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestCreateNameValidation(t *testing.T) {
+	e := &recordExec{}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+	fs := &Dataset{z: z, Name: "test/fs", Type: DatasetFilesystem}
+	snap := &Dataset{z: z, Name: "test/fs@snap", Type: DatasetSnapshot}
+
+	if _, err := z.CreateFilesystem("test/fs@snap", nil); err == nil {
+		t.Fatal("expected an error creating filesystem test/fs@snap")
+	}
+	if _, err := z.CreateVolume("test/vol!", 1024, nil); err == nil {
+		t.Fatal("expected an error creating volume test/vol!")
+	}
+	if _, err := fs.Snapshot("a/b", false); err == nil {
+		t.Fatal("expected an error creating snapshot a/b")
+	}
+	if _, err := snap.Clone("test/clone;", nil); err == nil {
+		t.Fatal("expected an error cloning into test/clone;")
+	}
+	if _, err := z.CreateZpool("mirror", nil, "/dev/sda"); err == nil {
+		t.Fatal("expected an error creating zpool mirror")
+	}
+	if _, err := z.CreateZpoolWithVdevs("raidz", nil, []VdevSpec{{Devices: []string{"/dev/sda"}}}, CreateZpoolOptions{}); err == nil {
+		t.Fatal("expected an error creating zpool raidz")
+	}
+	if e.cmd != "" {
+		t.Fatalf("expected no command, got: %s %v", e.cmd, e.args)
+	}
+}
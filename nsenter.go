@@ -0,0 +1,31 @@
+package zfs
+
+import (
+	"context"
+	"io"
+	"strconv"
+)
+
+// NewNsenterExecutor returns an Executor running the commands in the namespaces of the process with the given pid,
+// e.g. 1 to manage the host ZFS from inside a privileged container.
+// It requires the nsenter binary to be available.
+func NewNsenterExecutor(pid int) Executor {
+	return &nsenterExec{pid: pid, exec: NewLocalExecutor()}
+}
+
+type nsenterExec struct {
+	pid  int
+	exec Executor
+}
+
+func (n *nsenterExec) Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
+	return n.RunContext(context.Background(), stdin, stdout, stderr, cmd, args...)
+}
+
+func (n *nsenterExec) RunContext(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
+	nsArgs := []string{"--target", strconv.Itoa(n.pid), "--mount", "--uts", "--ipc", "--net", "--pid", "--", cmd}
+	if e, ok := n.exec.(ContextExecutor); ok {
+		return e.RunContext(ctx, stdin, stdout, stderr, "nsenter", append(nsArgs, args...)...)
+	}
+	return n.exec.Run(stdin, stdout, stderr, "nsenter", append(nsArgs, args...)...)
+}
@@ -0,0 +1,21 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNsenterExecutor(t *testing.T) {
+	r := &recordExec{}
+	e := &nsenterExec{pid: 1, exec: r}
+	if err := e.Run(nil, nil, nil, "zfs", "list", "-H"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.cmd != "nsenter" {
+		t.Fatalf("wanted: nsenter, got: %s", r.cmd)
+	}
+	want := []string{"--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--", "zfs", "list", "-H"}
+	if !reflect.DeepEqual(want, r.args) {
+		t.Fatalf("wanted: %v, got: %v", want, r.args)
+	}
+}
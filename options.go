@@ -1,10 +1,40 @@
 package zfs
 
+import (
+	"strconv"
+	"time"
+)
+
 type Option func(*zfs)
 
-func WithSudo() Option {
+// SudoOptions configures how sudo is invoked when running commands with WithSudo.
+// The zero value runs plain `sudo`.
+type SudoOptions struct {
+	// User runs the commands as the given user (sudo -u) instead of root.
+	User string
+	// NonInteractive makes sudo fail instead of prompting for a password (sudo -n).
+	NonInteractive bool
+}
+
+func (o SudoOptions) args() []string {
+	var args []string
+	if o.NonInteractive {
+		args = append(args, "-n")
+	}
+	if o.User != "" {
+		args = append(args, "-u", o.User)
+	}
+	return args
+}
+
+// WithSudo runs all the commands through sudo.
+// Optional SudoOptions may be passed to customize the sudo invocation.
+func WithSudo(opts ...SudoOptions) Option {
 	return func(z *zfs) {
 		z.sudo = true
+		if len(opts) > 0 {
+			z.sudoOpts = opts[0]
+		}
 	}
 }
 
@@ -14,8 +44,77 @@ func WithExecutor(exec Executor) Option {
 	}
 }
 
+// WithLogger sets the logger of the commands.
+// Each command is logged with a unique ID when it starts (START), and when it completes (FINISH) or fails (ERROR),
+// so that the lines of the commands run concurrently can be paired.
+// The logger is never called concurrently, so it does not need to be safe for concurrent use.
 func WithLogger(logger Logger) Option {
 	return func(z *zfs) {
-		z.logger = logger
+		if logger != nil {
+			z.logger = newLockedLogger(logger)
+		}
+	}
+}
+
+// WithTimeout stops any command which does not complete within d.
+// The command then fails with an Error wrapping ErrCommandTimeout.
+// The Executor must implement ContextExecutor, otherwise the timeout is ignored.
+func WithTimeout(d time.Duration) Option {
+	return func(z *zfs) {
+		z.timeout = d
+	}
+}
+
+// WithNice runs all the commands through `nice -n n`, to lower (or raise) their CPU scheduling priority.
+// The nice command must be installed on the host running the commands.
+// It composes with WithSudo, which is required to raise the priority with a negative n.
+func WithNice(n int) Option {
+	return func(z *zfs) {
+		z.nice = []string{"nice", "-n", strconv.Itoa(n)}
+	}
+}
+
+// The ionice scheduling classes which may be passed to WithIonice.
+const (
+	IoniceClassRealtime   = 1
+	IoniceClassBestEffort = 2
+	IoniceClassIdle       = 3
+)
+
+// WithIonice runs all the commands through `ionice -c class -n level`, to deprioritize their IO,
+// e.g. so that sends and receives do not starve the foreground IO.
+// The level ranges from 0 (highest priority) to 7 and is ignored for the idle class.
+// The ionice command must be installed on the host running the commands.
+// It composes with WithNice and WithSudo.
+func WithIonice(class, level int) Option {
+	return func(z *zfs) {
+		z.ionice = []string{"ionice", "-c", strconv.Itoa(class)}
+		if class != IoniceClassIdle {
+			z.ionice = append(z.ionice, "-n", strconv.Itoa(level))
+		}
+	}
+}
+
+// DryRunOptions configures the dry-run mode enabled with WithDryRun.
+type DryRunOptions struct {
+	// StubReads also skips the read only commands, such as zfs list or zfs get, which otherwise still run.
+	StubReads bool
+}
+
+// WithDryRun logs the commands which would modify anything, with a DRYRUN tag, instead of running them,
+// e.g. to preview destructive operations. The skipped commands succeed with an empty output.
+// The read only commands (zfs list, get, diff, version, userspace, groupspace, projectspace and holds,
+// zpool list, get, status, iostat and version) still run, unless DryRunOptions.StubReads is set.
+//
+// The methods which only return an error, such as Destroy, SetProperty or Rollback, are no-ops which succeed.
+// The methods which return the dataset or zpool they create or modify, such as CreateFilesystem, Snapshot or Clone,
+// read it back once the command succeeded, which fails as nothing was actually done.
+// Send and Receive are skipped as well: no stream is written nor read.
+func WithDryRun(opts ...DryRunOptions) Option {
+	return func(z *zfs) {
+		z.dryRun = &DryRunOptions{}
+		if len(opts) > 0 {
+			*z.dryRun = opts[0]
+		}
 	}
 }
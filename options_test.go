@@ -0,0 +1,202 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithSudo(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts []SudoOptions
+		want []string
+	}{
+		"default":         {want: []string{"zfs", "list"}},
+		"zero value":      {opts: []SudoOptions{{}}, want: []string{"zfs", "list"}},
+		"non interactive": {opts: []SudoOptions{{NonInteractive: true}}, want: []string{"-n", "zfs", "list"}},
+		"user":            {opts: []SudoOptions{{User: "zfsadmin"}}, want: []string{"-u", "zfsadmin", "zfs", "list"}},
+		"both":            {opts: []SudoOptions{{User: "zfsadmin", NonInteractive: true}}, want: []string{"-n", "-u", "zfsadmin", "zfs", "list"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &recordExec{}
+			z, err := New(WithExecutor(r), WithSudo(test.opts...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := z.(*zfs).run(nil, nil, "zfs", "list"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.cmd != "sudo" {
+				t.Fatalf("wanted: sudo, got: %s", r.cmd)
+			}
+			if !reflect.DeepEqual(test.want, r.args) {
+				t.Fatalf("wanted: %v, got: %v", test.want, r.args)
+			}
+		})
+	}
+}
+
+func TestWithNiceIonice(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts []Option
+		cmd  string
+		want []string
+	}{
+		"nice":        {opts: []Option{WithNice(10)}, cmd: "nice", want: []string{"-n", "10", "zfs", "send"}},
+		"ionice":      {opts: []Option{WithIonice(IoniceClassBestEffort, 7)}, cmd: "ionice", want: []string{"-c", "2", "-n", "7", "zfs", "send"}},
+		"ionice idle": {opts: []Option{WithIonice(IoniceClassIdle, 7)}, cmd: "ionice", want: []string{"-c", "3", "zfs", "send"}},
+		"both":        {opts: []Option{WithIonice(IoniceClassIdle, 0), WithNice(19)}, cmd: "nice", want: []string{"-n", "19", "ionice", "-c", "3", "zfs", "send"}},
+		"sudo": {
+			opts: []Option{WithNice(-5), WithSudo(SudoOptions{NonInteractive: true})},
+			cmd:  "sudo",
+			want: []string{"-n", "nice", "-n", "-5", "zfs", "send"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &recordExec{}
+			z, err := New(append(test.opts, WithExecutor(r))...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := z.(*zfs).run(nil, nil, "zfs", "send"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.cmd != test.cmd {
+				t.Fatalf("wanted: %s, got: %s", test.cmd, r.cmd)
+			}
+			if !reflect.DeepEqual(test.want, r.args) {
+				t.Fatalf("wanted: %v, got: %v", test.want, r.args)
+			}
+		})
+	}
+}
+
+// recordLogger records the logged command lines.
+type recordLogger struct {
+	lines [][]string
+}
+
+func (l *recordLogger) Log(cmd []string) {
+	l.lines = append(l.lines, cmd)
+}
+
+func TestWithDryRun(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts []DryRunOptions
+		args []string
+		run  bool
+	}{
+		"destroy":          {args: []string{"destroy", "-r", "test/fs"}},
+		"list":             {args: []string{"list", "-H", "test"}, run: true},
+		"usage":            {args: nil, run: true},
+		"stubbed list":     {opts: []DryRunOptions{{StubReads: true}}, args: []string{"list", "-H", "test"}},
+		"stubbed get":      {opts: []DryRunOptions{{StubReads: true}}, args: []string{"get", "all", "test"}},
+		"explicit options": {opts: []DryRunOptions{{}}, args: []string{"snapshot", "test@s"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &recordExec{}
+			l := &recordLogger{}
+			z, err := New(WithExecutor(r), WithLogger(l), WithDryRun(test.opts...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := z.(*zfs).run(nil, nil, "zfs", test.args...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ran := r.cmd != ""; ran != test.run {
+				t.Fatalf("wanted run: %v, got: %v", test.run, ran)
+			}
+			if len(l.lines) == 0 {
+				t.Fatal("command was not logged")
+			}
+			if dry := l.lines[0][1] == "DRYRUN"; dry == test.run {
+				t.Fatalf("wanted dry run log: %v, got: %v", !test.run, l.lines[0])
+			}
+		})
+	}
+}
+
+type requestIDKey struct{}
+
+// contextLogger records the request IDs of the logged commands.
+type contextLogger struct {
+	recordLogger
+	ids []string
+}
+
+func (l *contextLogger) LogContext(ctx context.Context, cmd []string) {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	l.ids = append(l.ids, id)
+	l.Log(cmd)
+}
+
+func TestWithContext(t *testing.T) {
+	l := &contextLogger{}
+	z, err := New(WithExecutor(&recordExec{}), WithLogger(l))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-42")
+	if _, err := z.WithContext(ctx).(*zfs).run(nil, nil, "zfs", "list"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual([]string{"req-42", "req-42"}, l.ids) {
+		t.Fatalf("wanted the request ID for the START and FINISH lines, got: %v", l.ids)
+	}
+	if z.(*zfs).ctx != nil {
+		t.Fatal("the original instance should not be bound to the context")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	z, err = New(WithExecutor(NewLocalExecutor()), WithLogger(l))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := z.WithContext(cctx).(*zfs).run(nil, nil, "sleep", "10"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wanted: %v, got: %v", context.Canceled, err)
+	}
+}
+
+func TestWithLoggerConcurrent(t *testing.T) {
+	l := &recordLogger{}
+	z, err := New(WithExecutor(&recordExec{fail: "destroy", stderr: "something went wrong", err: errors.New("exit status 1")}), WithLogger(l))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cmd := "list"
+			if i%2 == 0 {
+				cmd = "destroy"
+			}
+			z.(*zfs).run(nil, nil, "zfs", cmd)
+		}(i)
+	}
+	wg.Wait()
+
+	ends := make(map[string]string)
+	for _, line := range l.lines {
+		if line[1] != "START" {
+			ends[line[0]] = line[1]
+		}
+	}
+	if len(l.lines) != 40 || len(ends) != 20 {
+		t.Fatalf("wanted a START and an end line for each of the 20 commands, got: %v", l.lines)
+	}
+	var errs int
+	for _, line := range l.lines {
+		if line[1] == "START" && ends[line[0]] == "ERROR" && strings.Contains(line[2], "destroy") {
+			errs++
+		}
+	}
+	if errs != 10 {
+		t.Fatalf("wanted 10 failed destroy commands paired with their ERROR line, got: %d", errs)
+	}
+}
@@ -0,0 +1,153 @@
+package zfs
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SpaceUsage is the space used by a user, group or project in a filesystem, and its quota.
+type SpaceUsage struct {
+	// Type is the type of the identity, e.g. "POSIX User", "POSIX Group", "SMB User" or "Project".
+	Type string
+	// Name is the name of the user or group, or its numeric id when it cannot be resolved or when numeric ids are requested,
+	// or the project id.
+	Name string
+	// Numeric is true when Name is a numeric id rather than a resolved name.
+	Numeric bool
+	// Used is the space used, in bytes.
+	Used uint64
+	// Quota is the quota, in bytes, or 0 when no quota is set.
+	Quota uint64
+}
+
+// SpaceOptions are options for listing the space usage with UserSpace, GroupSpace and ProjectSpace.
+type SpaceOptions struct {
+	// Numeric reports the numeric ids of the users and groups instead of their names (-n).
+	// It is ignored by ProjectSpace, as projects only have numeric ids and zfs projectspace has no -n option.
+	Numeric bool
+}
+
+func (o SpaceOptions) args(cmd string) []string {
+	if o.Numeric && cmd != "projectspace" {
+		return []string{"-n"}
+	}
+	return nil
+}
+
+// SetUserQuota sets the quota of the user, by name or numeric id, in the receiving filesystem.
+// A quota of 0 removes the quota.
+func (d *Dataset) SetUserQuota(id string, quota uint64) error {
+	return d.setQuota("userquota", id, quota)
+}
+
+// GetUserQuota returns the quota of the user, by name or numeric id, in the receiving filesystem, or 0 when no quota is set.
+func (d *Dataset) GetUserQuota(id string) (uint64, error) {
+	return d.getQuota("userquota", id)
+}
+
+// SetGroupQuota sets the quota of the group, by name or numeric id, in the receiving filesystem.
+// A quota of 0 removes the quota.
+func (d *Dataset) SetGroupQuota(id string, quota uint64) error {
+	return d.setQuota("groupquota", id, quota)
+}
+
+// GetGroupQuota returns the quota of the group, by name or numeric id, in the receiving filesystem, or 0 when no quota is set.
+func (d *Dataset) GetGroupQuota(id string) (uint64, error) {
+	return d.getQuota("groupquota", id)
+}
+
+// SetProjectQuota sets the quota of the project id in the receiving filesystem.
+// A quota of 0 removes the quota.
+func (d *Dataset) SetProjectQuota(id string, quota uint64) error {
+	return d.setQuota("projectquota", id, quota)
+}
+
+// GetProjectQuota returns the quota of the project id in the receiving filesystem, or 0 when no quota is set.
+func (d *Dataset) GetProjectQuota(id string) (uint64, error) {
+	return d.getQuota("projectquota", id)
+}
+
+// UserSpace returns the space used by each user in the receiving filesystem, and their quotas (zfs userspace).
+func (d *Dataset) UserSpace(opts ...SpaceOptions) ([]SpaceUsage, error) {
+	return d.space("userspace", opts...)
+}
+
+// GroupSpace returns the space used by each group in the receiving filesystem, and their quotas (zfs groupspace).
+func (d *Dataset) GroupSpace(opts ...SpaceOptions) ([]SpaceUsage, error) {
+	return d.space("groupspace", opts...)
+}
+
+// ProjectSpace returns the space used by each project in the receiving filesystem, and their quotas (zfs projectspace).
+func (d *Dataset) ProjectSpace(opts ...SpaceOptions) ([]SpaceUsage, error) {
+	return d.space("projectspace", opts...)
+}
+
+func (d *Dataset) setQuota(prop, id string, quota uint64) error {
+	if id == "" {
+		return fmt.Errorf("%s id must not be empty", prop)
+	}
+	value := "none"
+	if quota > 0 {
+		value = strconv.FormatUint(quota, 10)
+	}
+	return d.z.do("set", fmt.Sprintf("%s@%s=%s", prop, id, value), d.Name)
+}
+
+func (d *Dataset) getQuota(prop, id string) (uint64, error) {
+	if id == "" {
+		return 0, fmt.Errorf("%s id must not be empty", prop)
+	}
+	out, err := d.z.doOutput("get", "-Hp", "-o", "value", prop+"@"+id, d.Name)
+	if err != nil {
+		return 0, err
+	}
+	if len(out) == 0 {
+		return 0, fmt.Errorf("unexpected output getting %s@%s of %s", prop, id, d.Name)
+	}
+	var quota uint64
+	if err := setQuota(&quota, out[0][0]); err != nil {
+		return 0, err
+	}
+	return quota, nil
+}
+
+func (d *Dataset) space(cmd string, opts ...SpaceOptions) ([]SpaceUsage, error) {
+	args := []string{cmd, "-Hp", "-o", "type,name,used,quota"}
+	if len(opts) > 0 {
+		args = append(args, opts[0].args(cmd)...)
+	}
+	out, err := d.z.doOutput(append(args, d.Name)...)
+	if err != nil {
+		return nil, err
+	}
+	usages := make([]SpaceUsage, 0, len(out))
+	for _, line := range out {
+		if len(line) < 4 {
+			return nil, fmt.Errorf("unexpected output of %s for %s", cmd, d.Name)
+		}
+		u := SpaceUsage{Type: line[0], Name: line[1], Numeric: isNumeric(line[1])}
+		if err := setUint(&u.Used, line[2]); err != nil {
+			return nil, err
+		}
+		if err := setQuota(&u.Quota, line[3]); err != nil {
+			return nil, err
+		}
+		usages = append(usages, u)
+	}
+	return usages, nil
+}
+
+// setQuota is like setUint, but also handles the "none" value of the quotas which are not set.
+func setQuota(field *uint64, value string) error {
+	if value == "none" {
+		*field = 0
+		return nil
+	}
+	return setUint(field, value)
+}
+
+// isNumeric reports whether the identity name is a numeric id.
+func isNumeric(name string) bool {
+	_, err := strconv.ParseUint(name, 10, 32)
+	return err == nil
+}
@@ -0,0 +1,104 @@
+package zfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuotas(t *testing.T) {
+	for name, test := range map[string]struct {
+		set   func(d *Dataset) error
+		get   func(d *Dataset) (uint64, error)
+		prop  string
+		quota string
+	}{
+		"user": {
+			set:  func(d *Dataset) error { return d.SetUserQuota("alice", 1024) },
+			get:  func(d *Dataset) (uint64, error) { return d.GetUserQuota("alice") },
+			prop: "userquota@alice",
+		},
+		"group": {
+			set:  func(d *Dataset) error { return d.SetGroupQuota("1000", 1024) },
+			get:  func(d *Dataset) (uint64, error) { return d.GetGroupQuota("1000") },
+			prop: "groupquota@1000",
+		},
+		"project": {
+			set:  func(d *Dataset) error { return d.SetProjectQuota("42", 1024) },
+			get:  func(d *Dataset) (uint64, error) { return d.GetProjectQuota("42") },
+			prop: "projectquota@42",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := &recordExec{stdout: "1024\n"}
+			d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/home"}
+			if err := test.set(d); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []string{"set", test.prop + "=1024", "test/home"}; !reflect.DeepEqual(want, e.args) {
+				t.Fatalf("wanted: %v, got: %v", want, e.args)
+			}
+			quota, err := test.get(d)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []string{"get", "-Hp", "-o", "value", test.prop, "test/home"}; !reflect.DeepEqual(want, e.args) {
+				t.Fatalf("wanted: %v, got: %v", want, e.args)
+			}
+			if quota != 1024 {
+				t.Fatalf("wanted: 1024, got: %d", quota)
+			}
+			e.stdout = "none\n"
+			if quota, err = test.get(d); err != nil || quota != 0 {
+				t.Fatalf("wanted no quota, got: %d, %v", quota, err)
+			}
+		})
+	}
+}
+
+func TestUserSpace(t *testing.T) {
+	e := &recordExec{stdout: "POSIX User\talice\t2048\t1048576\nPOSIX User\t1001\t512\tnone\n"}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/home"}
+
+	usages, err := d.UserSpace()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"userspace", "-Hp", "-o", "type,name,used,quota", "test/home"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	want := []SpaceUsage{
+		{Type: "POSIX User", Name: "alice", Used: 2048, Quota: 1048576},
+		{Type: "POSIX User", Name: "1001", Numeric: true, Used: 512},
+	}
+	if !reflect.DeepEqual(want, usages) {
+		t.Fatalf("wanted: %+v, got: %+v", want, usages)
+	}
+
+	e.stdout = "POSIX Group\t1000\t2048\t-\n"
+	usages, err = d.GroupSpace(SpaceOptions{Numeric: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"groupspace", "-Hp", "-o", "type,name,used,quota", "-n", "test/home"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	if want := []SpaceUsage{{Type: "POSIX Group", Name: "1000", Numeric: true, Used: 2048}}; !reflect.DeepEqual(want, usages) {
+		t.Fatalf("wanted: %+v, got: %+v", want, usages)
+	}
+}
+
+func TestProjectSpace(t *testing.T) {
+	e := &recordExec{stdout: "Project\t42\t4096\t1048576\n"}
+	d := &Dataset{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test/home"}
+
+	usages, err := d.ProjectSpace(SpaceOptions{Numeric: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"projectspace", "-Hp", "-o", "type,name,used,quota", "test/home"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	if want := []SpaceUsage{{Type: "Project", Name: "42", Numeric: true, Used: 4096, Quota: 1048576}}; !reflect.DeepEqual(want, usages) {
+		t.Fatalf("wanted: %+v, got: %+v", want, usages)
+	}
+}
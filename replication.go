@@ -0,0 +1,301 @@
+package zfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReplicationMismatchError is returned by VerifyReplication when a replicated snapshot does not match its source.
+type ReplicationMismatchError struct {
+	Source          string
+	SourceGUID      string
+	Destination     string
+	DestinationGUID string
+}
+
+// Error returns the string representation of a ReplicationMismatchError.
+func (e *ReplicationMismatchError) Error() string {
+	return fmt.Sprintf("snapshot %s (guid %s) does not match its source %s (guid %s)", e.Destination, e.DestinationGUID, e.Source, e.SourceGUID)
+}
+
+// VerifyReplication checks that the dst snapshot is a replica of the src snapshot by comparing their GUIDs,
+// which ZFS preserves when a snapshot is sent and received.
+// The snapshots may belong to different ZFS instances, e.g. a local one and a remote one.
+// A *ReplicationMismatchError is returned if the GUIDs differ.
+func VerifyReplication(src, dst *Dataset) error {
+	if src.Type != DatasetSnapshot || dst.Type != DatasetSnapshot {
+		return errors.New("can only verify the replication of snapshots")
+	}
+	srcGUID, err := src.GetProperty("guid")
+	if err != nil {
+		return err
+	}
+	dstGUID, err := dst.GetProperty("guid")
+	if err != nil {
+		return err
+	}
+	if srcGUID != dstGUID {
+		return &ReplicationMismatchError{
+			Source:          src.Name,
+			SourceGUID:      srcGUID,
+			Destination:     dst.Name,
+			DestinationGUID: dstGUID,
+		}
+	}
+	return nil
+}
+
+// ReplicateOptions are the options which may be passed to ReplicateResumable.
+type ReplicateOptions struct {
+	// Send are the options of the initial send, the resumed sends carry them in their token.
+	Send SendOptions
+	// Receive are the options of the receive, it is always resumable.
+	Receive ReceiveOptions
+	// Retries is the number of times an interrupted transfer is resumed before giving up.
+	Retries int
+	// Progress is called at the end of each transfer attempt.
+	Progress func(ReplicateProgress)
+}
+
+// ReplicateProgress describes a transfer attempt of ReplicateResumable.
+type ReplicateProgress struct {
+	// Attempt is the number of the attempt, starting at 0 for the initial transfer.
+	Attempt int
+	// Bytes is the size of the stream transferred during the attempt.
+	Bytes uint64
+	// Err is the error which interrupted the attempt, or nil if it succeeded.
+	Err error
+}
+
+// ReplicateResumable sends the src snapshot to the dataset with the specified name of the dst ZFS instance,
+// which may be a remote one, e.g. using an SSH executor.
+// The stream is received with the resumable option: when the transfer fails, e.g. due to a network error,
+// it is resumed from the receive_resume_token of the destination, up to opts.Retries times.
+// The error of the last attempt is returned when the transfer cannot be resumed.
+// An error is returned before sending anything if name is not a valid dataset name, see IsValidDatasetName.
+// An error wrapping ErrNotSupported is returned before sending anything if the installed zfs version cannot send
+// with the options, see ZFSCapabilities.CheckSend, or if the destination zpool cannot receive the stream,
+// see Zpool.CanReceive. They are only checked when the LargeBlocks, EmbedData, Raw or RedactBookmark options are set.
+func ReplicateResumable(src *Dataset, dst ZFS, name string, opts ReplicateOptions) (*ReceiveResult, error) {
+	if src.Type != DatasetSnapshot {
+		return nil, errors.New("can only replicate snapshots")
+	}
+	if err := checkDatasetName(name); err != nil {
+		return nil, err
+	}
+	fs := name
+	if i := strings.Index(name, "@"); i >= 0 {
+		fs = name[:i]
+	}
+	if err := checkReplicateSend(src.z, dst, name, opts.Send); err != nil {
+		return nil, err
+	}
+	ropts := opts.Receive
+	ropts.Resumable = true
+	send := func(w io.Writer) error {
+		return src.Send(w, opts.Send)
+	}
+	for attempt := 0; ; attempt++ {
+		res, n, err := transfer(send, dst, name, ropts)
+		if opts.Progress != nil {
+			opts.Progress(ReplicateProgress{Attempt: attempt, Bytes: n, Err: err})
+		}
+		if err == nil {
+			return res, nil
+		}
+		if attempt >= opts.Retries {
+			return nil, err
+		}
+		token := resumeToken(dst, fs)
+		if token == "" {
+			return nil, err
+		}
+		send = func(w io.Writer) error {
+			return src.z.ResumeSend(token, w)
+		}
+	}
+}
+
+// checkReplicateSend checks that the send options are supported by the source zfs version, see ZFSCapabilities.CheckSend,
+// and that the destination zpool of the dataset with the specified name can receive the stream, see Zpool.CanReceive.
+// The checks only run for the options which require them, so that the other sends need neither the usage of zfs send
+// nor the zpool command on the destination.
+func checkReplicateSend(src *zfs, dst ZFS, name string, opts SendOptions) error {
+	features := opts.LargeBlocks || opts.EmbedData || opts.Raw
+	if !features && opts.RedactBookmark == "" {
+		return nil
+	}
+	caps, err := src.Capabilities()
+	if err != nil {
+		return err
+	}
+	if err := caps.CheckSend(opts); err != nil {
+		return err
+	}
+	if !features {
+		return nil
+	}
+	pool := name
+	if i := strings.IndexAny(name, "/@#"); i >= 0 {
+		pool = name[:i]
+	}
+	if pool == "" {
+		return fmt.Errorf("invalid dataset name %q: missing zpool name", name)
+	}
+	p, err := dst.GetZpool(pool)
+	if err != nil {
+		return err
+	}
+	return p.CanReceive(opts)
+}
+
+// IncrementalOptions are the options which may be passed to ReplicateIncremental.
+type IncrementalOptions struct {
+	// Snapshot is the name of a snapshot to take on the source before replicating it,
+	// otherwise the latest existing snapshot of the source is replicated.
+	Snapshot string
+	// Send are the options of the send, the Replicate and RedactBookmark options are not supported.
+	Send SendOptions
+	// Receive are the options of the receive.
+	Receive ReceiveOptions
+}
+
+// ReplicateIncremental brings the dst filesystem or volume up to date with the latest snapshot of the src one,
+// which may belong to different ZFS instances, e.g. a local one and a remote one using an SSH executor.
+// The stream is sent incrementally from the latest snapshot they have in common, as identified by its GUID,
+// so that the snapshots renamed on either side are still matched.
+// When they have no snapshot in common, the latest snapshot is sent in full and received with the Force option,
+// provided that the destination has no snapshots yet: an error is returned otherwise, as the replicas have diverged.
+// Nothing is sent when the destination already has the latest snapshot.
+func ReplicateIncremental(src, dst *Dataset, opts ...IncrementalOptions) error {
+	var o IncrementalOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Send.Replicate || o.Send.RedactBookmark != "" {
+		return errors.New("the Replicate and RedactBookmark send options are not supported")
+	}
+	if (src.Type != DatasetFilesystem && src.Type != DatasetVolume) || (dst.Type != DatasetFilesystem && dst.Type != DatasetVolume) {
+		return errors.New("can only replicate filesystems and volumes")
+	}
+	if o.Snapshot != "" {
+		if _, err := src.Snapshot(o.Snapshot, false); err != nil {
+			return err
+		}
+	}
+	srcSnaps, err := src.snapshotGUIDs()
+	if err != nil {
+		return err
+	}
+	if len(srcSnaps) == 0 {
+		return fmt.Errorf("%s has no snapshot to replicate", src.Name)
+	}
+	dstSnaps, err := dst.snapshotGUIDs()
+	if err != nil {
+		return err
+	}
+	dstGUIDs := make(map[string]bool, len(dstSnaps))
+	for _, s := range dstSnaps {
+		dstGUIDs[s.guid] = true
+	}
+	latest, base := srcSnaps[len(srcSnaps)-1], ""
+	for i := len(srcSnaps) - 1; i >= 0; i-- {
+		if dstGUIDs[srcSnaps[i].guid] {
+			base = srcSnaps[i].name
+			break
+		}
+	}
+	ropts := o.Receive
+	switch {
+	case base == latest.name:
+		return nil
+	case base == "" && len(dstSnaps) > 0:
+		return fmt.Errorf("%s and %s have no snapshot in common", src.Name, dst.Name)
+	case base == "":
+		ropts.Force = true
+	}
+	send := func(w io.Writer) error {
+		args := append([]string{"send"}, o.Send.args()...)
+		if base != "" {
+			args = append(args, "-i", base)
+		}
+		w, closePipeline := pipeline(w, o.Send.Pipeline)
+		_, err := src.z.run(nil, w, "zfs", append(args, latest.name)...)
+		if cerr := closePipeline(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	_, _, err = transfer(send, dst.z, dst.Name, ropts)
+	return err
+}
+
+// snapshotGUID is the name and the GUID of a snapshot.
+type snapshotGUID struct {
+	name string
+	guid string
+}
+
+// snapshotGUIDs returns the snapshots of the receiving dataset along with their GUIDs, from the oldest to the newest.
+func (d *Dataset) snapshotGUIDs() ([]snapshotGUID, error) {
+	out, err := d.z.doOutput("list", "-Hp", "-t", DatasetSnapshot, "-d", "1", "-s", "createtxg", "-o", "name,guid", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	snaps := make([]snapshotGUID, 0, len(out))
+	for _, line := range out {
+		if len(line) < 2 {
+			return nil, fmt.Errorf("unexpected output listing the snapshots of %s", d.Name)
+		}
+		snaps = append(snaps, snapshotGUID{name: line[0], guid: line[1]})
+	}
+	return snaps, nil
+}
+
+// resumeToken returns the receive_resume_token of the dataset, or an empty string if it has none.
+func resumeToken(z ZFS, name string) string {
+	ds, err := z.GetDataset(name)
+	if err != nil {
+		return ""
+	}
+	token, err := ds.GetProperty("receive_resume_token")
+	if err != nil || token == "-" {
+		return ""
+	}
+	return token
+}
+
+// countWriter counts the bytes written through it.
+type countWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += uint64(n)
+	return n, err
+}
+
+// transfer pipes the stream written by send into the receive of the named dataset on dst.
+// It returns the number of bytes received, and the first error of the send and the receive, see pipe.
+func transfer(send func(io.Writer) error, dst ZFS, name string, opts ReceiveOptions) (*ReceiveResult, uint64, error) {
+	var (
+		res *ReceiveResult
+		cw  countWriter
+	)
+	err := pipe(func(w io.Writer) error {
+		cw.w = w
+		return send(&cw)
+	}, func(r io.Reader) error {
+		var err error
+		res, err = dst.Receive(r, name, opts)
+		return err
+	})
+	if err != nil {
+		return nil, cw.n, err
+	}
+	return res, cw.n, nil
+}
@@ -0,0 +1,247 @@
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// interruptedExec returns an executor whose first receives are interrupted, and which reports a resume token for test/dst.
+func interruptedExec(failures int) *recordExec {
+	r := &recordExec{outputs: map[string]string{
+		"zfs list": testDatasetLine("test/dst", "-"),
+		"zfs get":  "test/dst\treceive_resume_token\t1-abc-def\t-\n",
+	}}
+	if failures > 0 {
+		r.fail, r.err, r.failures = "receive", errors.New("connection reset by peer"), failures
+	}
+	return r
+}
+
+// commandLines returns the lines beginning with one of the prefixes.
+func commandLines(lines []string, prefixes ...string) []string {
+	var got []string
+	for _, l := range lines {
+		for _, p := range prefixes {
+			if strings.HasPrefix(l, p) {
+				got = append(got, l)
+				break
+			}
+		}
+	}
+	return got
+}
+
+func TestReplicateResumable(t *testing.T) {
+	s := &recordExec{stdout: "stream"}
+	src := testSnapshot(s)
+	r := interruptedExec(1)
+	dst := &zfs{exec: r, logger: &defaultLogger{}}
+
+	var progress []ReplicateProgress
+	res, err := ReplicateResumable(src, dst, "test/dst", ReplicateOptions{
+		Retries:  2,
+		Progress: func(p ReplicateProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Dataset.Name != "test/dst" {
+		t.Fatalf("wanted: test/dst, got: %s", res.Dataset.Name)
+	}
+	want := []string{"zfs send test/fs@snap", "zfs send -t 1-abc-def"}
+	if !reflect.DeepEqual(want, s.lines) {
+		t.Fatalf("wanted: %v, got: %v", want, s.lines)
+	}
+	if receives := commandLines(r.lines, "zfs receive"); len(receives) != 2 {
+		t.Fatalf("wanted 2 receives, got: %v", receives)
+	}
+	if !reflect.DeepEqual([]string{"stream", "stream"}, r.stdins) {
+		t.Fatalf("unexpected received streams: %v", r.stdins)
+	}
+	if len(progress) != 2 || progress[0].Err == nil || progress[1].Err != nil || progress[1].Attempt != 1 || progress[1].Bytes != 6 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	if lines := commandLines(r.lines, "zpool"); len(lines) != 0 {
+		t.Fatalf("the destination zpool should not be looked up without a send option requiring a feature: %v", lines)
+	}
+}
+
+func TestReplicateResumableUnsupportedSend(t *testing.T) {
+	src := testSnapshot(&recordExec{
+		stderrs: map[string]string{"zfs send": oldSendUsage, "zfs receive": oldReceiveUsage, "zpool": zpoolUsage},
+		err:     errUsage,
+	})
+	r := interruptedExec(0)
+	dst := &zfs{exec: r, logger: &defaultLogger{}}
+
+	_, err := ReplicateResumable(src, dst, "test/dst", ReplicateOptions{Send: SendOptions{Raw: true}})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("wanted: %v, got: %v", ErrNotSupported, err)
+	}
+	if len(r.lines) != 0 {
+		t.Fatalf("expected no command on the destination, got: %v", r.lines)
+	}
+}
+
+func TestReplicateResumableInvalidName(t *testing.T) {
+	for _, name := range []string{"", "/", "@", "@snap", "/test"} {
+		src := testSnapshot(&recordExec{stdout: "stream"})
+		r := interruptedExec(0)
+		dst := &zfs{exec: r, logger: &defaultLogger{}}
+
+		if _, err := ReplicateResumable(src, dst, name, ReplicateOptions{Send: SendOptions{Raw: true}}); err == nil {
+			t.Fatalf("%q: expected error", name)
+		}
+		if len(r.lines) != 0 {
+			t.Fatalf("%q: expected no command on the destination, got: %v", name, r.lines)
+		}
+	}
+}
+
+func TestReplicateResumableGivesUp(t *testing.T) {
+	src := testSnapshot(&recordExec{stdout: "stream"})
+	r := interruptedExec(3)
+	dst := &zfs{exec: r, logger: &defaultLogger{}}
+
+	_, err := ReplicateResumable(src, dst, "test/dst@snap", ReplicateOptions{Retries: 1})
+	if err == nil || !strings.Contains(err.Error(), "connection reset by peer") {
+		t.Fatalf("expected the error of the last attempt, got: %v", err)
+	}
+	if receives := commandLines(r.lines, "zfs receive"); len(receives) != 2 {
+		t.Fatalf("wanted 2 attempts, got: %v", receives)
+	}
+}
+
+func TestReplicateIncremental(t *testing.T) {
+	const (
+		srcSnaps  = "test/src@a\t1\ntest/src@b\t2\ntest/src@c\t3\n"
+		listSnaps = "zfs list -Hp -t snapshot -d 1 -s createtxg -o name,guid "
+	)
+	for name, test := range map[string]struct {
+		dstSnaps string
+		opts     []IncrementalOptions
+		want     []string
+		err      bool
+	}{
+		"incremental": {
+			// the common snapshot was renamed on the destination
+			dstSnaps: "test/dst@a\t1\ntest/dst@renamed\t2\n",
+			opts:     []IncrementalOptions{{Send: SendOptions{Raw: true}}},
+			want:     []string{"zfs receive test/dst", "zfs send -w -i test/src@b test/src@c"},
+		},
+		"initial": {
+			want: []string{"zfs receive -F test/dst", "zfs send test/src@c"},
+		},
+		"up to date": {
+			dstSnaps: "test/dst@c\t3\n",
+		},
+		"fresh snapshot": {
+			dstSnaps: "test/dst@c\t3\n",
+			opts:     []IncrementalOptions{{Snapshot: "d"}},
+			want:     []string{"zfs receive test/dst", "zfs send -i test/src@c test/src@d", "zfs snapshot test/src@d"},
+		},
+		"diverged": {
+			dstSnaps: "test/dst@x\t42\n",
+			err:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := &recordExec{
+				outputs: map[string]string{
+					listSnaps + "test/src": srcSnaps,
+					listSnaps + "test/dst": test.dstSnaps,
+					"zfs list test/dst":    testDatasetLine("test/dst", "-"),
+					"zfs list test/src@d":  testDatasetLine("test/src@d", "-"),
+					"zfs send":             "stream",
+				},
+				// the snapshot taken is listed afterwards
+				updates: map[string]map[string]string{
+					"zfs snapshot test/src@d": {listSnaps + "test/src": srcSnaps + "test/src@d\t4\n"},
+				},
+			}
+			z := &zfs{exec: e, logger: &defaultLogger{}}
+			src := &Dataset{z: z, Name: "test/src", Type: DatasetFilesystem}
+			dst := &Dataset{z: z, Name: "test/dst", Type: DatasetFilesystem}
+
+			err := ReplicateIncremental(src, dst, test.opts...)
+			if (err != nil) != test.err {
+				t.Fatalf("wanted error: %v, got: %v", test.err, err)
+			}
+			got := commandLines(e.lines, "zfs send", "zfs receive", "zfs snapshot")
+			sort.Strings(got)
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSendTo(t *testing.T) {
+	s := &recordExec{stdout: "stream"}
+	r := interruptedExec(0)
+	dst := &zfs{exec: r, logger: &defaultLogger{}}
+
+	res, err := testSnapshot(s).SendTo(dst, "test/dst", SendOptions{Compressed: true}, ReceiveOptions{NoMount: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Dataset.Name != "test/dst" {
+		t.Fatalf("wanted: test/dst, got: %s", res.Dataset.Name)
+	}
+	if want := []string{"zfs send -c test/fs@snap"}; !reflect.DeepEqual(want, s.lines) {
+		t.Fatalf("wanted: %v, got: %v", want, s.lines)
+	}
+	if !reflect.DeepEqual([]string{"stream"}, r.stdins) {
+		t.Fatalf("unexpected received streams: %v", r.stdins)
+	}
+
+	// the receive of the truncated stream fails as well, but the send failed first
+	dst = &zfs{exec: interruptedExec(1), logger: &defaultLogger{}}
+	// the send writes part of a stream before failing
+	_, err = testSnapshot(&recordExec{stdout: "str", err: errors.New("send failed")}).SendTo(dst, "test/dst", SendOptions{}, ReceiveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "send failed") {
+		t.Fatalf("wanted the send error, got: %v", err)
+	}
+}
+
+// zeroReader is an endless stream of zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func TestTransferReceiveFailure(t *testing.T) {
+	before := runtime.NumGoroutine()
+	// the receive reads some of the stream before failing
+	dst := &zfs{exec: &recordExec{limit: 8192, err: errors.New("receive failed")}, logger: &defaultLogger{}}
+	for i := 0; i < 10; i++ {
+		done := make(chan error, 1)
+		go func() {
+			_, err := testSnapshot(&recordExec{stream: zeroReader{}}).SendTo(dst, "test/dst", SendOptions{}, ReceiveOptions{})
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), "receive failed") {
+				t.Fatalf("wanted the receive error, got: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the transfer is deadlocked")
+		}
+	}
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("goroutines leaked: %d before, %d after", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
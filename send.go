@@ -0,0 +1,339 @@
+package zfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// SendOptions are the options which may be passed to Send.
+//
+// More information about the send options may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-send.8.html
+type SendOptions struct {
+	// Replicate sends the snapshot along with all the descendent filesystems and their snapshots (zfs send -R).
+	Replicate bool
+	// Properties includes the properties of the dataset in the stream, so that they are set on the received dataset (zfs send -p).
+	// They are always included in a replication stream.
+	Properties bool
+	// Raw sends encrypted datasets as they are on disk, without decrypting them (zfs send -w).
+	Raw bool
+	// Compressed keeps the compressed blocks compressed in the stream (zfs send -c).
+	Compressed bool
+	// LargeBlocks allows blocks larger than 128KiB in the stream (zfs send -L).
+	LargeBlocks bool
+	// EmbedData generates a more compact stream using embedded data records (zfs send -e).
+	EmbedData bool
+	// RedactBookmark sends a redacted stream, omitting the blocks recorded in the redaction bookmark (zfs send --redact).
+	// It cannot be combined with Replicate, see Dataset.Redact to create the bookmark.
+	RedactBookmark string
+	// Pipeline transforms the stream before it is written to the output, e.g. to compress it.
+	// The stream goes through the stages in order, the last one writing to the output.
+	// The stages are closed in the same order once the send completes, so that each one
+	// flushes into the next, and the first close error is returned.
+	Pipeline []func(io.Writer) io.WriteCloser
+}
+
+func (o SendOptions) args() []string {
+	var args []string
+	if o.Replicate {
+		args = append(args, "-R")
+	}
+	if o.Properties {
+		args = append(args, "-p")
+	}
+	if o.Raw {
+		args = append(args, "-w")
+	}
+	if o.Compressed {
+		args = append(args, "-c")
+	}
+	if o.LargeBlocks {
+		args = append(args, "-L")
+	}
+	if o.EmbedData {
+		args = append(args, "-e")
+	}
+	if o.RedactBookmark != "" {
+		args = append(args, "--redact", o.RedactBookmark)
+	}
+	return args
+}
+
+// ReceiveOptions are the options which may be passed to Receive.
+//
+// More information about the receive options may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-receive.8.html
+type ReceiveOptions struct {
+	// Force rolls back the destination to its most recent snapshot before receiving (zfs receive -F).
+	Force bool
+	// NoMount does not mount the received filesystem (zfs receive -u).
+	NoMount bool
+	// Resumable saves the partially received state on failure, so that the receive can be resumed (zfs receive -s).
+	Resumable bool
+	// Properties are set on the received dataset, overriding the values from the stream (zfs receive -o).
+	Properties map[string]string
+	// Exclude are the properties of the stream which are not received, so that the received dataset
+	// inherits them or keeps its default values, e.g. mountpoint when sending with properties (zfs receive -x).
+	Exclude []string
+}
+
+func (o ReceiveOptions) args() []string {
+	var args []string
+	if o.Force {
+		args = append(args, "-F")
+	}
+	if o.NoMount {
+		args = append(args, "-u")
+	}
+	if o.Resumable {
+		args = append(args, "-s")
+	}
+	if o.Properties != nil {
+		args = append(args, propsSlice(o.Properties)...)
+	}
+	for _, p := range o.Exclude {
+		args = append(args, "-x", p)
+	}
+	return args
+}
+
+// Send sends a ZFS stream of a snapshot to the output io.Writer, using the given options.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) Send(output io.Writer, opts SendOptions) error {
+	if d.Type != DatasetSnapshot {
+		return errors.New("can only send snapshots")
+	}
+	if opts.RedactBookmark != "" && opts.Replicate {
+		return errors.New("cannot send a replication stream with redaction")
+	}
+	args := append([]string{"send"}, opts.args()...)
+	args = append(args, d.Name)
+	w, closePipeline := pipeline(output, opts.Pipeline)
+	_, err := d.z.run(nil, w, "zfs", args...)
+	if cerr := closePipeline(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// SendPlan is what a send would transfer, as estimated by ZFS without producing the stream.
+type SendPlan struct {
+	// Streams are the streams of the send, there are several when the descendent datasets
+	// or the intermediate snapshots are sent.
+	Streams []SendPlanStream
+	// Size is the estimated total size of the send.
+	Size uint64
+}
+
+// SendPlanStream is a stream of a SendPlan.
+type SendPlanStream struct {
+	// FromName is the base of an incremental stream, or empty for a full stream.
+	FromName string
+	// ToName is the snapshot sent by the stream.
+	ToName string
+	// Size is the estimated size of the stream.
+	Size uint64
+}
+
+// SendPlan returns what sending the receiving snapshot with the given options would transfer,
+// using a dry-run send (zfs send -nvP), e.g. to validate a replication before running it.
+// The send is incremental from the from snapshot or bookmark, or a full send if from is nil.
+// The Pipeline option is ignored.
+func (d *Dataset) SendPlan(from *Dataset, opts SendOptions) (*SendPlan, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only send snapshots")
+	}
+	if opts.RedactBookmark != "" && opts.Replicate {
+		return nil, errors.New("cannot send a replication stream with redaction")
+	}
+	args := append([]string{"send", "-nvP"}, opts.args()...)
+	if from != nil {
+		if from.Type != DatasetSnapshot && from.Type != DatasetBookmark {
+			return nil, errors.New("can only send incrementally from snapshots or bookmarks")
+		}
+		args = append(args, "-i", from.Name)
+	}
+	args = append(args, d.Name)
+	out, err := d.z.doOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSendPlan(out)
+}
+
+// parseSendPlan parses the parsable verbose output of a dry-run send, made of the lines:
+// full <toname> <size>, incremental <fromname> <toname> <size> and size <total>.
+func parseSendPlan(out [][]string) (*SendPlan, error) {
+	plan := &SendPlan{}
+	for _, line := range out {
+		var (
+			s    SendPlanStream
+			size string
+		)
+		switch {
+		case line[0] == "full" && len(line) >= 3:
+			s.ToName, size = line[1], line[2]
+		case line[0] == "incremental" && len(line) >= 4:
+			s.FromName, s.ToName, size = line[1], line[2], line[3]
+		case line[0] == "size" && len(line) >= 2:
+			if err := setUint(&plan.Size, line[1]); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			continue
+		}
+		if err := setUint(&s.Size, size); err != nil {
+			return nil, err
+		}
+		plan.Streams = append(plan.Streams, s)
+	}
+	if len(plan.Streams) == 0 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	return plan, nil
+}
+
+// pipeline chains the stages in front of output.
+// It returns the writer of the first stage, and a function closing all the stages from the first to the last.
+func pipeline(output io.Writer, stages []func(io.Writer) io.WriteCloser) (io.Writer, func() error) {
+	w := output
+	closers := make([]io.Closer, 0, len(stages))
+	for i := len(stages) - 1; i >= 0; i-- {
+		wc := stages[i](w)
+		closers = append(closers, wc)
+		w = wc
+	}
+	return w, func() error {
+		var err error
+		for i := len(closers) - 1; i >= 0; i-- {
+			if cerr := closers[i].Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+}
+
+// SendToFile sends a ZFS stream of a snapshot to the file at path, using the given options.
+// The file is created or truncated, and synced to disk once the stream is complete.
+// It is removed if the send fails, so that no partial stream is left behind.
+func (d *Dataset) SendToFile(path string, opts SendOptions) error {
+	if d.Type != DatasetSnapshot {
+		return errors.New("can only send snapshots")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := d.Send(f, opts); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// SendTo sends a ZFS stream of the receiving snapshot into the dataset with the specified name of the dst ZFS instance,
+// e.g. from a local executor to a remote one using an SSH executor.
+// The send and the receive run concurrently, connected by a pipe, and the first error of either side is returned.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) SendTo(dst ZFS, name string, sendOpts SendOptions, recvOpts ReceiveOptions) (*ReceiveResult, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only send snapshots")
+	}
+	res, _, err := transfer(func(w io.Writer) error {
+		return d.Send(w, sendOpts)
+	}, dst, name, recvOpts)
+	return res, err
+}
+
+// ResumeSend resumes an interrupted send to the output io.Writer, using the receive_resume_token
+// of the dataset which was being received into, which must have been received with the Resumable option.
+//
+// More information about resumable sends may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-send.8.html
+func (z *zfs) ResumeSend(token string, output io.Writer) error {
+	if token == "" || token == "-" {
+		return errors.New("no receive resume token")
+	}
+	_, err := z.run(nil, output, "zfs", "send", "-t", token)
+	return err
+}
+
+// ReceiveResult is the result of a successful Receive.
+type ReceiveResult struct {
+	// Dataset is the received dataset.
+	Dataset *Dataset
+	// Warnings are the non-fatal messages printed by zfs receive,
+	// e.g. about properties which could not be set.
+	Warnings []string
+}
+
+// Receive receives a ZFS stream from the input io.Reader into the dataset with the specified name, using the given options.
+func (z *zfs) Receive(input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	args := append([]string{"receive"}, opts.args()...)
+	args = append(args, name)
+	_, stderr, err := z.runStderr(input, nil, "zfs", args...)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := z.GetDataset(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiveResult{Dataset: ds, Warnings: warnings(stderr)}, nil
+}
+
+// warnings splits the stderr of a successful command into its non-blank lines.
+func warnings(stderr string) []string {
+	var w []string
+	for _, l := range strings.Split(stderr, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			w = append(w, l)
+		}
+	}
+	return w
+}
+
+// BackupReceiveOptions returns the options used by ReceiveBackup, which may be modified and passed to Receive
+// to override them: the destination is rolled back to its most recent snapshot (-F), is not mounted (-u),
+// and the received dataset is set with canmount=off and readonly=on (-o).
+func BackupReceiveOptions() ReceiveOptions {
+	return ReceiveOptions{
+		Force:   true,
+		NoMount: true,
+		Properties: map[string]string{
+			"canmount": "off",
+			"readonly": "on",
+		},
+	}
+}
+
+// ReceiveBackup receives a ZFS stream from the input io.Reader into a backup dataset with the specified name,
+// which is neither mounted nor writable, using the BackupReceiveOptions:
+// zfs receive -F -u -o canmount=off -o readonly=on name.
+func (z *zfs) ReceiveBackup(input io.Reader, name string) (*ReceiveResult, error) {
+	return z.Receive(input, name, BackupReceiveOptions())
+}
+
+// ReceiveFromFile receives a ZFS stream from the file at path into the dataset with the specified name, using the given options.
+func (z *zfs) ReceiveFromFile(path, name string, opts ReceiveOptions) (*ReceiveResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return z.Receive(f, name, opts)
+}
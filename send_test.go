@@ -0,0 +1,281 @@
+package zfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordCloser records the order in which the pipeline stages are closed.
+type recordCloser struct {
+	io.Writer
+	i      int
+	closed *[]int
+	err    error
+}
+
+func (c recordCloser) Close() error {
+	*c.closed = append(*c.closed, c.i)
+	return c.err
+}
+
+func testSnapshot(e Executor) *Dataset {
+	return &Dataset{
+		z:     &zfs{exec: e, logger: &defaultLogger{}},
+		Name:  "test/fs@snap",
+		Type:  DatasetSnapshot,
+		props: make(map[string]string),
+	}
+}
+
+func TestSendPipeline(t *testing.T) {
+	const stream = "zfs stream content"
+	d := testSnapshot(&recordExec{stdout: stream})
+
+	var out bytes.Buffer
+	err := d.Send(&out, SendOptions{Pipeline: []func(io.Writer) io.WriteCloser{
+		func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		func(w io.Writer) io.WriteCloser {
+			return base64.NewEncoder(base64.StdEncoding, w)
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, &out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("truncated stream: %v", err)
+	}
+	if string(got) != stream {
+		t.Fatalf("wanted: %q, got: %q", stream, got)
+	}
+}
+
+func TestSendPipelineCloseError(t *testing.T) {
+	d := testSnapshot(&recordExec{stdout: "stream"})
+
+	var closed []int
+	stage := func(i int, err error) func(io.Writer) io.WriteCloser {
+		return func(w io.Writer) io.WriteCloser {
+			return recordCloser{Writer: w, i: i, closed: &closed, err: err}
+		}
+	}
+	first, second := errors.New("first"), errors.New("second")
+	err := d.Send(io.Discard, SendOptions{Pipeline: []func(io.Writer) io.WriteCloser{
+		stage(0, nil),
+		stage(1, first),
+		stage(2, second),
+	}})
+	if !errors.Is(err, first) {
+		t.Fatalf("expected the first close error, got: %v", err)
+	}
+	if len(closed) != 3 || closed[0] != 0 || closed[1] != 1 || closed[2] != 2 {
+		t.Fatalf("stages closed in the wrong order: %v", closed)
+	}
+}
+
+func TestSendRedact(t *testing.T) {
+	r := &recordExec{}
+	d := testSnapshot(r)
+
+	if err := d.Send(io.Discard, SendOptions{RedactBookmark: "test/fs#redacted"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"send", "--redact", "test/fs#redacted", "test/fs@snap"}
+	if !reflect.DeepEqual(want, r.args) {
+		t.Fatalf("wanted: %v, got: %v", want, r.args)
+	}
+
+	r.args = nil
+	if err := d.Send(io.Discard, SendOptions{RedactBookmark: "test/fs#redacted", Replicate: true}); err == nil {
+		t.Fatal("expected error when combining redaction with replication")
+	}
+	if r.args != nil {
+		t.Fatalf("command should not have run, got: %v", r.args)
+	}
+}
+
+func TestReceiveWarnings(t *testing.T) {
+	e := &recordExec{
+		outputs: map[string]string{"zfs list": testDatasetLine("test/fs", "/test/fs")},
+		stderrs: map[string]string{"zfs receive": "cannot receive quota property on test/fs: permission denied\n\n"},
+	}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+	res, err := z.Receive(strings.NewReader("stream"), "test/fs", ReceiveOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Dataset.Name != "test/fs" {
+		t.Fatalf("wanted: test/fs, got: %s", res.Dataset.Name)
+	}
+	want := []string{"cannot receive quota property on test/fs: permission denied"}
+	if !reflect.DeepEqual(want, res.Warnings) {
+		t.Fatalf("wanted: %q, got: %q", want, res.Warnings)
+	}
+}
+
+func TestIncrementalSendMissingBase(t *testing.T) {
+	r := &recordExec{
+		outputs: map[string]string{"zfs get": "test/fs@snap\t1234\n"},
+		stderrs: map[string]string{"zfs get": "cannot open 'test/fs@base': dataset does not exist\n"},
+		fail:    "get",
+		err:     errors.New("exit status 1"),
+	}
+	d := testSnapshot(r)
+	base := &Dataset{z: d.z, Name: "test/fs@base", Type: DatasetSnapshot}
+
+	err := d.IncrementalSend(base, io.Discard)
+	if !errors.Is(err, ErrBaseSnapshotMissing) {
+		t.Fatalf("wanted: %v, got: %v", ErrBaseSnapshotMissing, err)
+	}
+	if r.args[0] == "send" {
+		t.Fatal("send should not have run")
+	}
+	if !strings.Contains(err.Error(), "bookmark") {
+		t.Fatalf("expected a hint to send from a bookmark, got: %v", err)
+	}
+
+	r.stderrs["zfs get"] = "cannot open 'test/fs#base': dataset does not exist\n"
+	err = d.IncrementalSendFrom(&Dataset{z: d.z, Name: "test/fs#base", Type: DatasetBookmark}, io.Discard)
+	if !errors.Is(err, ErrBaseSnapshotMissing) {
+		t.Fatalf("wanted: %v, got: %v", ErrBaseSnapshotMissing, err)
+	}
+	if strings.Contains(err.Error(), "bookmark") {
+		t.Fatalf("unexpected hint to send from a bookmark: %v", err)
+	}
+
+	r = &recordExec{outputs: map[string]string{"zfs get": "test/fs@base\t1234\ntest/fs@snap\t1234\n"}}
+	d.z.exec = r
+	if err := d.IncrementalSend(base, io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.args[0] != "send" {
+		t.Fatal("send should have run")
+	}
+}
+
+func TestIntermediarySend(t *testing.T) {
+	r := &recordExec{outputs: map[string]string{"zfs get": "test/fs@base\t1234\ntest/fs@snap\t1234\n"}}
+	d := testSnapshot(r)
+	base := &Dataset{z: d.z, Name: "test/fs@base", Type: DatasetSnapshot}
+
+	if err := d.IntermediarySend(base, io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"send", "-I", "test/fs@base", "test/fs@snap"}
+	if !reflect.DeepEqual(want, r.args) {
+		t.Fatalf("wanted: %v, got: %v", want, r.args)
+	}
+
+	r.args = nil
+	for _, b := range []*Dataset{
+		{z: d.z, Name: "test/other@base", Type: DatasetSnapshot},
+		{z: d.z, Name: "test/fs#base", Type: DatasetBookmark},
+	} {
+		if err := d.IntermediarySend(b, io.Discard); err == nil {
+			t.Fatalf("expected an error sending from %s", b.Name)
+		}
+	}
+	if r.args != nil {
+		t.Fatalf("command should not have run, got: %v", r.args)
+	}
+
+	r.outputs["zfs get"] = "test/fs@snap\t1234\n"
+	r.stderrs = map[string]string{"zfs get": "cannot open 'test/fs@base': dataset does not exist\n"}
+	r.fail, r.err = "get", errors.New("exit status 1")
+	if err := d.IntermediarySend(base, io.Discard); !errors.Is(err, ErrBaseSnapshotMissing) {
+		t.Fatalf("wanted: %v, got: %v", ErrBaseSnapshotMissing, err)
+	}
+	if r.args[0] == "send" {
+		t.Fatal("send should not have run")
+	}
+}
+
+func TestZpoolCanReceive(t *testing.T) {
+	features := "feature@large_blocks\tenabled\nfeature@embedded_data\tactive\nfeature@encryption\tdisabled\n"
+	p := &Zpool{z: &zfs{exec: &recordExec{stdout: features}, logger: &defaultLogger{}}, Name: "test"}
+	if err := p.CanReceive(SendOptions{LargeBlocks: true, EmbedData: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.CanReceive(SendOptions{Raw: true}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("wanted: %v, got: %v", ErrNotSupported, err)
+	}
+}
+
+func TestReceiveBackup(t *testing.T) {
+	h := &recordExec{outputs: map[string]string{"zfs list": testDatasetLine("backup/fs", "-")}}
+	z := &zfs{exec: h, logger: &defaultLogger{}}
+	if _, err := z.ReceiveBackup(strings.NewReader("stream"), "backup/fs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "zfs receive -F -u -o canmount=off -o readonly=on backup/fs"
+	if h.lines[0] != want {
+		t.Fatalf("wanted: %s, got: %s", want, h.lines[0])
+	}
+}
+
+func TestSendReceiveProperties(t *testing.T) {
+	r := &recordExec{}
+	if err := testSnapshot(r).Send(io.Discard, SendOptions{Properties: true, Raw: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"send", "-p", "-w", "test/fs@snap"}
+	if !reflect.DeepEqual(want, r.args) {
+		t.Fatalf("wanted: %v, got: %v", want, r.args)
+	}
+
+	h := &recordExec{outputs: map[string]string{"zfs list": testDatasetLine("backup/fs", "-")}}
+	z := &zfs{exec: h, logger: &defaultLogger{}}
+	opts := ReceiveOptions{NoMount: true, Properties: map[string]string{"readonly": "on"}, Exclude: []string{"mountpoint", "com.example:owner"}}
+	if _, err := z.Receive(strings.NewReader("stream"), "backup/fs", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantLine := "zfs receive -u -o readonly=on -x mountpoint -x com.example:owner backup/fs"
+	if h.lines[0] != wantLine {
+		t.Fatalf("wanted: %s, got: %s", wantLine, h.lines[0])
+	}
+}
+
+func TestSendPlan(t *testing.T) {
+	const out = "incremental\ttest/fs@base\ttest/fs@snap\t1048576\n" +
+		"full\ttest/fs/child@snap\t524288\n" +
+		"size\t1572864\n"
+	r := &recordExec{}
+	d := testSnapshot(&recordExec{stdout: out})
+	base := &Dataset{z: d.z, Name: "test/fs@base", Type: DatasetSnapshot}
+
+	plan, err := d.SendPlan(base, SendOptions{Replicate: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &SendPlan{
+		Streams: []SendPlanStream{
+			{FromName: "test/fs@base", ToName: "test/fs@snap", Size: 1048576},
+			{ToName: "test/fs/child@snap", Size: 524288},
+		},
+		Size: 1572864,
+	}
+	if !reflect.DeepEqual(want, plan) {
+		t.Fatalf("wanted: %+v, got: %+v", want, plan)
+	}
+
+	d.z.exec = r
+	if _, err := d.SendPlan(nil, SendOptions{Raw: true}); err == nil {
+		t.Fatal("expected error for an empty output")
+	}
+	wantArgs := []string{"send", "-nvP", "-w", "test/fs@snap"}
+	if !reflect.DeepEqual(wantArgs, r.args) {
+		t.Fatalf("wanted: %v, got: %v", wantArgs, r.args)
+	}
+}
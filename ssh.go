@@ -1,25 +1,68 @@
 package zfs
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"regexp"
 	"strings"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 )
 
+// NewSSHExecutor returns an Executor running the commands over the given ssh connection.
 func NewSSHExecutor(c *ssh.Client) Executor {
 	return &sshExec{c: c}
 }
 
+// NewSSHExecutorWithDialer returns an Executor running the commands over an ssh connection obtained from dial.
+// The connection is checked with a keepalive request before each command, and dialed again if it was dropped.
+func NewSSHExecutorWithDialer(dial func() (*ssh.Client, error)) Executor {
+	return &sshExec{dial: dial}
+}
+
 type sshExec struct {
-	c *ssh.Client
+	mu   sync.Mutex
+	c    *ssh.Client
+	dial func() (*ssh.Client, error)
+}
+
+// client returns a healthy connection, reconnecting if a dialer is available.
+func (s *sshExec) client() (*ssh.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dial == nil {
+		return s.c, nil
+	}
+	if s.c != nil {
+		if _, _, err := s.c.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+			return s.c, nil
+		}
+		s.c.Close()
+		s.c = nil
+	}
+	c, err := s.dial()
+	if err != nil {
+		return nil, fmt.Errorf("ssh: dial: %w", err)
+	}
+	s.c = c
+	return c, nil
 }
 
 func (s *sshExec) Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
-	sess, err := s.c.NewSession()
+	return s.RunContext(context.Background(), stdin, stdout, stderr, cmd, args...)
+}
+
+func (s *sshExec) RunContext(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd string, args ...string) error {
+	c, err := s.client()
 	if err != nil {
 		return err
 	}
+	sess, err := c.NewSession()
+	if err != nil {
+		return fmt.Errorf("ssh: new session: %w", err)
+	}
 	defer sess.Close()
 	if stdin != nil {
 		sess.Stdin = stdin
@@ -30,5 +73,42 @@ func (s *sshExec) Run(stdin io.Reader, stdout io.Writer, stderr io.Writer, cmd s
 	if stderr != nil {
 		sess.Stderr = stderr
 	}
-	return sess.Run(strings.Join(append([]string{cmd}, args...), " "))
+	if err := sess.Start(shellJoin(append([]string{cmd}, args...))); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- sess.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// not all servers support signals, closing the session is what actually stops the command
+		_ = sess.Signal(ssh.SIGKILL)
+		sess.Close()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// shellJoin quotes each argument for the remote POSIX shell and joins them with spaces.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellSafe matches the arguments which do not need to be quoted.
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s so that a POSIX shell reads it back as a single word.
+// Arguments only made of safe characters are returned as is to keep the commands readable.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
@@ -0,0 +1,198 @@
+package zfs
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestShellQuote(t *testing.T) {
+	for name, test := range map[string]struct {
+		arg  string
+		want string
+	}{
+		"plain":         {arg: "test/fs@snap", want: "test/fs@snap"},
+		"property":      {arg: "compression=lz4", want: "compression=lz4"},
+		"empty":         {arg: "", want: "''"},
+		"space":         {arg: "comment=hello world", want: "'comment=hello world'"},
+		"single quote":  {arg: "it's", want: `'it'\''s'`},
+		"double quote":  {arg: `say "hi"`, want: `'say "hi"'`},
+		"dollar":        {arg: "$HOME", want: "'$HOME'"},
+		"glob":          {arg: "/mnt/*", want: "'/mnt/*'"},
+		"command":       {arg: "a;rm -rf /", want: "'a;rm -rf /'"},
+		"backtick":      {arg: "`id`", want: "'`id`'"},
+		"newline":       {arg: "a\nb", want: "'a\nb'"},
+		"hash":          {arg: "fs#bookmark", want: "'fs#bookmark'"},
+		"trailing tick": {arg: "a'", want: `'a'\'''`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := shellQuote(test.arg); got != test.want {
+				t.Fatalf("wanted: %s, got: %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestShellJoinRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	args := []string{"set", "comment=hello world", `it's "quoted"`, "$HOME", "/mnt/*", "a;b", "`id`", "", "test/fs@snap", "test/my fs@daily snap"}
+	// print every argument the shell parsed from the joined command, NUL separated
+	script := `f() { for a in "$@"; do printf '%s\0' "$a"; done; }; f ` + shellJoin(args)
+	out, err := exec.Command(sh, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if !reflect.DeepEqual(args, got) {
+		t.Fatalf("wanted: %q, got: %q", args, got)
+	}
+}
+
+// sshServer is an in-process ssh server echoing the commands it runs, recording on which connection each one ran.
+type sshServer struct {
+	config *ssh.ServerConfig
+	l      net.Listener
+
+	mu       sync.Mutex
+	conns    []*ssh.ServerConn
+	commands []string
+}
+
+func newSSHServer(t *testing.T) *sshServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &sshServer{config: &ssh.ServerConfig{NoClientAuth: true}, l: l}
+	s.config.AddHostKey(signer)
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	return s
+}
+
+// dial connects a new client to the server.
+func (s *sshServer) dial() (*ssh.Client, error) {
+	return ssh.Dial("tcp", s.l.Addr().String(), &ssh.ClientConfig{User: "test", HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+}
+
+func (s *sshServer) serve(nc net.Conn) {
+	conn, chans, reqs, err := ssh.NewServerConn(nc, s.config)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	id := len(s.conns)
+	s.mu.Unlock()
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		if nch.ChannelType() != "session" {
+			nch.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for r := range chReqs {
+				if r.Type != "exec" {
+					r.Reply(false, nil)
+					continue
+				}
+				var exec struct{ Command string }
+				if err := ssh.Unmarshal(r.Payload, &exec); err != nil {
+					r.Reply(false, nil)
+					continue
+				}
+				r.Reply(true, nil)
+				s.mu.Lock()
+				s.commands = append(s.commands, fmt.Sprintf("%d: %s", id, exec.Command))
+				s.mu.Unlock()
+				io.WriteString(ch, exec.Command+"\n")
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				ch.Close()
+			}
+		}()
+	}
+}
+
+func TestSSHExecutorReconnect(t *testing.T) {
+	s := newSSHServer(t)
+	dials := 0
+	e := NewSSHExecutorWithDialer(func() (*ssh.Client, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return s.dial()
+	})
+	run := func() (string, error) {
+		var out bytes.Buffer
+		err := e.Run(nil, &out, io.Discard, "zfs", "list", "test/my fs")
+		return out.String(), err
+	}
+
+	if _, err := run(); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("wanted the dial error, got: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		out, err := run()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "zfs list 'test/my fs'\n"; out != want {
+			t.Fatalf("wanted: %q, got: %q", want, out)
+		}
+	}
+	if dials != 2 {
+		t.Fatalf("wanted the healthy connection to be reused, got %d dials", dials)
+	}
+
+	// drop the connection: the keepalive fails and the command runs on a new one
+	s.mu.Lock()
+	s.conns[0].Close()
+	s.mu.Unlock()
+	if _, err := run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dials != 3 {
+		t.Fatalf("wanted the dropped connection to be dialed again, got %d dials", dials)
+	}
+	want := []string{"1: zfs list 'test/my fs'", "1: zfs list 'test/my fs'", "2: zfs list 'test/my fs'"}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !reflect.DeepEqual(want, s.commands) {
+		t.Fatalf("wanted: %q, got: %q", want, s.commands)
+	}
+}
@@ -1,24 +1,42 @@
 package zfs
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 func (z *zfs) run(in io.Reader, out io.Writer, cmd string, args ...string) ([][]string, error) {
+	lines, _, err := z.runStderr(in, out, cmd, args...)
+	return lines, err
+}
+
+// runStderr is like run, but also returns the stderr of a successful command, which may hold warnings.
+func (z *zfs) runStderr(in io.Reader, out io.Writer, cmd string, args ...string) ([][]string, string, error) {
 	var stdout, stderr bytes.Buffer
 
+	stub := z.dryRun != nil && (z.dryRun.StubReads || !readOnly(cmd, args))
+
+	if z.ionice != nil {
+		cmd, args = wrap(z.ionice, cmd, args)
+	}
+	if z.nice != nil {
+		cmd, args = wrap(z.nice, cmd, args)
+	}
 	if z.sudo {
-		args = append([]string{cmd}, args...)
-		cmd = "sudo"
+		cmd, args = wrap(append([]string{"sudo"}, z.sudoOpts.args()...), cmd, args)
 	}
 
 	cmdOut := out
@@ -29,32 +47,301 @@ func (z *zfs) run(in io.Reader, out io.Writer, cmd string, args ...string) ([][]
 	id := uuid.New().String()
 	joinedArgs := strings.Join(args, " ")
 
-	z.logger.Log([]string{"ID:" + id, "START", joinedArgs})
-	if err := z.exec.Run(in, cmdOut, &stderr, cmd, args...); err != nil {
-		return nil, &Error{
+	ctx := z.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if z.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, z.timeout)
+		defer cancel()
+	}
+
+	if stub {
+		z.log(ctx, []string{"ID:" + id, "DRYRUN", joinedArgs})
+		return nil, "", nil
+	}
+
+	z.log(ctx, []string{"ID:" + id, "START", joinedArgs})
+	if err := z.execute(ctx, in, cmdOut, &stderr, cmd, args...); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = ErrCommandTimeout
+		}
+		zErr := &Error{
 			Err:    err,
 			Debug:  strings.Join([]string{cmd, joinedArgs}, " "),
 			Stderr: stderr.String(),
+			Stdout: stdout.String(),
 		}
+		z.log(ctx, []string{"ID:" + id, "ERROR", zErr.Error()})
+		return nil, "", zErr
 	}
-	z.logger.Log([]string{"ID:" + id, "FINISH"})
+	z.log(ctx, []string{"ID:" + id, "FINISH"})
 
 	// assume if you passed in something for stdout, that you know what to do with it
 	if out != nil {
-		return nil, nil
+		return nil, stderr.String(), nil
+	}
+
+	return parseOutput(stdout.String()), stderr.String(), nil
+}
+
+// runLines is like run, but calls fn with the fields of each line of the output as soon as the command prints it,
+// instead of buffering the whole output, so that large outputs are processed with a bounded memory.
+// If fn returns an error, the command is stopped and runLines returns that error.
+func (z *zfs) runLines(fn func(line []string) error, cmd string, args ...string) error {
+	r, w := io.Pipe()
+	var fnErr error
+	done := make(chan error, 1)
+	go func() {
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			if strings.TrimSpace(s.Text()) == "" {
+				continue
+			}
+			if fnErr = fn(strings.Split(s.Text(), "\t")); fnErr != nil {
+				// fail the writes so that the command stops
+				r.CloseWithError(fnErr)
+				done <- nil
+				return
+			}
+		}
+		r.CloseWithError(s.Err())
+		done <- s.Err()
+	}()
+	_, err := z.run(nil, w, cmd, args...)
+	w.CloseWithError(err)
+	scanErr := <-done
+	// the command fails when it is stopped, report why instead
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return err
+	}
+	return scanErr
+}
+
+// pipe runs write and read concurrently, connected by an io.Pipe, e.g. a send and a receive.
+// A failure of either side closes the pipe, so that the other side fails as well instead of blocking forever:
+// the first error is returned, as it is the cause of the failure.
+// pipe returns once both sides completed.
+func pipe(write func(io.Writer) error, read func(io.Reader) error) error {
+	var (
+		mu    sync.Mutex
+		first error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if first == nil {
+			first = err
+		}
+	}
+	pr, pw := io.Pipe()
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		err := write(pw)
+		if err != nil {
+			fail(err)
+		}
+		pw.CloseWithError(err)
+	}()
+	if err := read(pr); err != nil {
+		fail(err)
+	}
+	// unblock the writer if the reader stopped reading
+	pr.Close()
+	<-written
+	return first
+}
+
+// readCommands are the zfs and zpool sub commands which do not modify anything.
+var readCommands = map[string][]string{
+	"zfs":   {"list", "get", "diff", "version", "userspace", "groupspace", "projectspace", "holds"},
+	"zpool": {"list", "get", "status", "iostat", "version"},
+}
+
+// dryRunCommands are the zfs and zpool sub commands with the dry-run flags they are run with to only report what they would do.
+var dryRunCommands = map[string]map[string]string{
+	"zfs": {
+		"send":    "-nvP",
+		"destroy": "-nvp",
+	},
+	"zpool": {
+		"create": "-n",
+	},
+}
+
+// readOnly reports whether the command line does not modify anything, the commands without arguments only print their usage.
+// The dry-run commands are read only as well, as is zfs mount without arguments, which lists the mounted filesystems.
+func readOnly(cmd string, args []string) bool {
+	if len(args) == 0 || contains(readCommands[cmd], args[0]) {
+		return true
+	}
+	if cmd == "zfs" && len(args) == 1 && args[0] == "mount" {
+		return true
+	}
+	flag, ok := dryRunCommands[cmd][args[0]]
+	return ok && len(args) > 1 && args[1] == flag
+}
+
+// wrap prefixes the command line with the wrapper command line, e.g. sudo or nice.
+func wrap(wrapper []string, cmd string, args []string) (string, []string) {
+	wrapped := make([]string, 0, len(wrapper)+len(args))
+	wrapped = append(append(wrapped, wrapper[1:]...), cmd)
+	return wrapper[0], append(wrapped, args...)
+}
+
+// lockedLogger serializes the calls to a Logger, so that loggers which are not safe for concurrent use
+// can be shared by the commands run concurrently.
+type lockedLogger struct {
+	mu     sync.Mutex
+	logger Logger
+}
+
+func newLockedLogger(l Logger) Logger {
+	if _, ok := l.(*lockedLogger); ok {
+		return l
+	}
+	return &lockedLogger{logger: l}
+}
+
+func (l *lockedLogger) Log(cmd []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger.Log(cmd)
+}
+
+func (l *lockedLogger) LogContext(ctx context.Context, cmd []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cl, ok := l.logger.(ContextLogger); ok {
+		cl.LogContext(ctx, cmd)
+		return
+	}
+	l.logger.Log(cmd)
+}
+
+// log logs the command fields, with the context when the logger is a ContextLogger.
+func (z *zfs) log(ctx context.Context, fields []string) {
+	if l, ok := z.logger.(ContextLogger); ok {
+		l.LogContext(ctx, fields)
+		return
+	}
+	z.logger.Log(fields)
+}
+
+// execute runs the command with the executor, through its context aware variant when available.
+func (z *zfs) execute(ctx context.Context, in io.Reader, out, errOut io.Writer, cmd string, args ...string) error {
+	if e, ok := z.exec.(ContextExecutor); ok {
+		return e.RunContext(ctx, in, out, errOut, cmd, args...)
+	}
+	return z.exec.Run(in, out, errOut, cmd, args...)
+}
+
+// parseOutput splits a command output into lines of tab separated fields, as printed by the scripting mode (-H)
+// of the zfs and zpool commands, so that values containing spaces are kept whole.
+// Blank lines are skipped, so that an empty output or one without a trailing newline are handled alike.
+func parseOutput(stdout string) [][]string {
+	var output [][]string
+	for _, l := range strings.Split(stdout, "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		output = append(output, strings.Split(l, "\t"))
+	}
+
+	return output
+}
+
+// onlyMissingDatasets reports whether err is a command failure caused only by datasets which do not exist.
+func onlyMissingDatasets(err error) bool {
+	var zErr *Error
+	if !errors.As(err, &zErr) || zErr.Stderr == "" {
+		return false
+	}
+	for _, l := range strings.Split(strings.TrimSpace(zErr.Stderr), "\n") {
+		if !strings.HasSuffix(l, "dataset does not exist") {
+			return false
+		}
+	}
+	return true
+}
+
+// noDatasetsAvailable reports whether err is a list failure only telling that no dataset matched.
+func noDatasetsAvailable(err error) bool {
+	var zErr *Error
+	if !errors.As(err, &zErr) {
+		return false
+	}
+	return strings.TrimSpace(zErr.Stderr) == "no datasets available"
+}
+
+// unknownProperty reports whether err is a command failure caused by a property unknown to the installed zfs version.
+func unknownProperty(err error) bool {
+	var zErr *Error
+	if !errors.As(err, &zErr) {
+		return false
 	}
+	return strings.Contains(zErr.Stderr, "bad property list")
+}
+
+// maxPropertyNameLen is the maximum length of a ZFS property name.
+const maxPropertyNameLen = 255
 
-	lines := strings.Split(stdout.String(), "\n")
+// userPropertyName builds a `module:name` user property name and validates it against the ZFS naming rules:
+// only lowercase letters, numbers, ':', '-', '.' and '_' are allowed.
+func userPropertyName(module, name string) (string, error) {
+	if module == "" || name == "" {
+		return "", errors.New("user property module and name must not be empty")
+	}
+	if strings.Contains(module, ":") {
+		return "", fmt.Errorf("user property module %q must not contain ':'", module)
+	}
+	key := module + ":" + name
+	if len(key) > maxPropertyNameLen {
+		return "", fmt.Errorf("user property %q is too long", key)
+	}
+	for _, c := range key {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && !strings.ContainsRune(":-._", c) {
+			return "", fmt.Errorf("user property %q contains invalid character %q", key, c)
+		}
+	}
+	return key, nil
+}
 
-	// last line is always blank
-	lines = lines[0 : len(lines)-1]
-	output := make([][]string, len(lines))
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
 
-	for i, l := range lines {
-		output[i] = strings.Fields(l)
+// subUint64 returns a - b, or 0 if b is greater than a.
+func subUint64(a, b uint64) uint64 {
+	if b > a {
+		return 0
 	}
+	return a - b
+}
 
-	return output, nil
+// dsPropAbbreviations maps the ZFS property names to their abbreviations, which are also accepted by zfs.
+var dsPropAbbreviations = map[string]string{
+	"available":            "avail",
+	"compression":          "compress",
+	"referenced":           "refer",
+	"logicalused":          "lused",
+	"usedbydataset":        "usedds",
+	"refreservation":       "refreserv",
+	"usedbysnapshots":      "usedsnap",
+	"usedbychildren":       "usedchild",
+	"usedbyrefreservation": "usedrefreserv",
+	"encryptionroot":       "encroot",
+	"logicalreferenced":    "lrefer",
+	"compressratio":        "ratio",
+	"refcompressratio":     "refratio",
 }
 
 func setString(field *string, value string) {
@@ -78,35 +365,65 @@ func setUint(field *uint64, value string) error {
 	return nil
 }
 
-func (d *Dataset) parseProps(out [][]string) error {
-	var err error
-
-	if len(out) != 2 {
-		return errors.New("output does not match what is expected on this platform")
+// setTime parses a time property, reported as the number of seconds since the Unix epoch, e.g. creation.
+func setTime(field *time.Time, value string) error {
+	var v time.Time
+	if value != "-" {
+		sec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v = time.Unix(sec, 0)
 	}
-	for i, v := range out[0] {
-		val := "-"
-		if i < len(out[1]) {
-			val = out[1][i]
+	*field = v
+	return nil
+}
+
+// setRatio parses a compression or deduplication ratio, trimming its trailing "x", e.g. "1.50x".
+func setRatio(field *float64, value string) error {
+	var v float64
+	if value != "-" {
+		var err error
+		v, err = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+		if err != nil {
+			return err
 		}
-		d.props[strings.ToLower(v)] = val
 	}
+	*field = v
+	return nil
+}
+
+func (d *Dataset) parseProps(line []string) error {
+	var err error
 
-	if len(d.props) <= len(dsPropList) {
+	props := dsPropList
+	if len(line) == len(dsPropListLegacy) {
+		props = dsPropListLegacy
+	}
+	if len(line) != len(props) {
 		return errors.New("output does not match what is expected on this platform")
 	}
+	// the properties unknown to older zfs versions are not available, as zfs reports it
+	for _, v := range dsPropList {
+		d.props[v] = "-"
+	}
+	for i, v := range props {
+		d.props[v] = line[i]
+	}
+
 	setString(&d.Name, d.props["name"])
 	setString(&d.Origin, d.props["origin"])
 
 	if err = setUint(&d.Used, d.props["used"]); err != nil {
 		return err
 	}
-	if err = setUint(&d.Avail, d.props["avail"]); err != nil {
+	if err = setUint(&d.Avail, d.props["available"]); err != nil {
 		return err
 	}
 
 	setString(&d.Mountpoint, d.props["mountpoint"])
-	setString(&d.Compression, d.props["compress"])
+	d.Mounted = d.props["mounted"] == "yes"
+	setString(&d.Compression, d.props["compression"])
 	setString(&d.Type, d.props["type"])
 
 	if err = setUint(&d.Volsize, d.props["volsize"]); err != nil {
@@ -115,7 +432,10 @@ func (d *Dataset) parseProps(out [][]string) error {
 	if err = setUint(&d.Quota, d.props["quota"]); err != nil {
 		return err
 	}
-	if err = setUint(&d.Referenced, d.props["refer"]); err != nil {
+	if err = setUint(&d.Referenced, d.props["referenced"]); err != nil {
+		return err
+	}
+	if err = setTime(&d.Creation, d.props["creation"]); err != nil {
 		return err
 	}
 
@@ -126,12 +446,51 @@ func (d *Dataset) parseProps(out [][]string) error {
 	if err = setUint(&d.Written, d.props["written"]); err != nil {
 		return err
 	}
-	if err = setUint(&d.Logicalused, d.props["lused"]); err != nil {
+	if err = setUint(&d.Logicalused, d.props["logicalused"]); err != nil {
+		return err
+	}
+	if err = setUint(&d.Usedbydataset, d.props["usedbydataset"]); err != nil {
+		return err
+	}
+	if err = setUint(&d.Refquota, d.props["refquota"]); err != nil {
+		return err
+	}
+	if err = setUint(&d.Refreservation, d.props["refreservation"]); err != nil {
+		return err
+	}
+	if err = setUint(&d.Usedbysnapshots, d.props["usedbysnapshots"]); err != nil {
 		return err
 	}
-	if err = setUint(&d.Usedbydataset, d.props["usedds"]); err != nil {
+	if err = setUint(&d.Usedbychildren, d.props["usedbychildren"]); err != nil {
 		return err
 	}
+	if err = setUint(&d.Usedbyrefreservation, d.props["usedbyrefreservation"]); err != nil {
+		return err
+	}
+
+	if err = setUint(&d.LogicalReferenced, d.props["logicalreferenced"]); err != nil {
+		return err
+	}
+	if err = setRatio(&d.CompressRatio, d.props["compressratio"]); err != nil {
+		return err
+	}
+	if err = setRatio(&d.RefCompressRatio, d.props["refcompressratio"]); err != nil {
+		return err
+	}
+
+	if err = setUint(&d.CreateTxg, d.props["createtxg"]); err != nil {
+		return err
+	}
+	if err = setUint(&d.ObjsetID, d.props["objsetid"]); err != nil {
+		return err
+	}
+	if err = setUint(&d.GUID, d.props["guid"]); err != nil {
+		return err
+	}
+
+	setString(&d.Encryption, d.props["encryption"])
+	setString(&d.KeyStatus, d.props["keystatus"])
+	setString(&d.EncryptionRoot, d.props["encryptionroot"])
 	return nil
 }
 
@@ -282,44 +641,92 @@ func parseInodeChanges(lines [][]string) ([]*InodeChange, error) {
 	return changes, nil
 }
 
+// listByType lists the datasets of type t matching the filter.
+// Some ZFS versions exit with an error when no dataset matches, instead of an empty output:
+// both are returned as an empty result.
 func (z *zfs) listByType(t, filter string) ([]*Dataset, error) {
-	args := []string{"list", "-rp", "-t", t, "-o", "all"}
+	ds, err := z.List(filter, ListOptions{Types: []string{t}})
+	if noDatasetsAvailable(err) {
+		return nil, nil
+	}
+	return ds, err
+}
 
+func (o ListOptions) args() []string {
+	args := []string{"list"}
+	if o.Depth > 0 {
+		args = append(args, "-d", strconv.FormatUint(o.Depth, 10))
+	} else {
+		args = append(args, "-r")
+	}
+	if o.SortBy != "" {
+		if o.SortDescending {
+			args = append(args, "-S", o.SortBy)
+		} else {
+			args = append(args, "-s", o.SortBy)
+		}
+	}
+	types := "all"
+	if len(o.Types) > 0 {
+		types = strings.Join(o.Types, ",")
+	}
+	return append(args, "-Hp", "-t", types, "-o", dsPropListOptions)
+}
+
+func (z *zfs) list(filter string, opts ListOptions) ([]*Dataset, error) {
+	args := opts.args()
 	if filter != "" {
 		args = append(args, filter)
 	}
-	out, err := z.doOutput(args...)
+	var datasets []*Dataset
+	err := z.listLines(func(line []string) error {
+		ds := &Dataset{z: z, props: make(map[string]string)}
+		if err := ds.parseProps(line); err != nil {
+			return err
+		}
+		datasets = append(datasets, ds)
+		return nil
+	}, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(out) == 0 {
-		return nil, nil
-	}
-
-	var datasets []*Dataset
+	return datasets, nil
+}
 
-	name := ""
-	var ds *Dataset
-	for _, line := range out[1:] {
-		if name != line[0] {
-			name = line[0]
-			ds = &Dataset{z: z, Name: name, props: make(map[string]string)}
-			datasets = append(datasets, ds)
-		}
-		if err := ds.parseProps([][]string{out[0], line}); err != nil {
-			return nil, err
+// listLines is like runLines for a zfs list of the dsPropList properties, but lists the dsPropListLegacy ones instead
+// when the installed zfs version does not know all of them, e.g. the encryption properties before zfs 0.8.
+func (z *zfs) listLines(fn func(line []string) error, args ...string) error {
+	err := z.runLines(fn, "zfs", args...)
+	if !unknownProperty(err) || dsPropListLegacyOptions == dsPropListOptions {
+		return err
+	}
+	legacy := make([]string, len(args))
+	for i, v := range args {
+		if v == dsPropListOptions {
+			v = dsPropListLegacyOptions
 		}
+		legacy[i] = v
 	}
+	return z.runLines(fn, "zfs", legacy...)
+}
 
-	return datasets, nil
+// sortedKeys returns the names of the properties in sorted order.
+func sortedKeys(properties map[string]string) []string {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
+// propsSlice builds the -o arguments of the properties, sorted by name so that the command lines are deterministic.
 func propsSlice(properties map[string]string) []string {
-	args := make([]string, 0, len(properties)*3)
-	for k, v := range properties {
+	args := make([]string, 0, len(properties)*2)
+	for _, k := range sortedKeys(properties) {
 		args = append(args, "-o")
-		args = append(args, fmt.Sprintf("%s=%s", k, v))
+		args = append(args, fmt.Sprintf("%s=%s", k, properties[k]))
 	}
 	return args
 }
@@ -328,6 +735,11 @@ func (z *Zpool) parseLine(line []string) error {
 	prop := line[1]
 	val := line[2]
 
+	if z.props == nil {
+		z.props = make(map[string]string)
+	}
+	z.props[prop] = val
+
 	var err error
 
 	switch prop {
@@ -335,6 +747,8 @@ func (z *Zpool) parseLine(line []string) error {
 		setString(&z.Name, val)
 	case "health":
 		setString(&z.Health, val)
+	case "guid":
+		err = setUint(&z.GUID, val)
 	case "allocated":
 		err = setUint(&z.Allocated, val)
 	case "size":
@@ -355,8 +769,88 @@ func (z *Zpool) parseLine(line []string) error {
 	case "leaked":
 		err = setUint(&z.Leaked, val)
 	case "dedupratio":
-		// Trim trailing "x" before parsing float64
-		z.DedupRatio, err = strconv.ParseFloat(val[:len(val)-1], 64)
+		// Unavailable or suspended pools may not report a ratio
+		err = setRatio(&z.DedupRatio, val)
+	case "ashift":
+		err = setUint(&z.Ashift, val)
+	case "autoexpand":
+		z.Autoexpand = val == "on"
+	case "autoreplace":
+		z.Autoreplace = val == "on"
+	case "autotrim":
+		z.Autotrim = val == "on"
+	case "cachefile":
+		setString(&z.Cachefile, val)
+	case "bootfs":
+		setString(&z.Bootfs, val)
 	}
 	return err
 }
+
+// vdevStatClasses maps the headers of the allocation classes in the zpool list -v output to the vdev classes.
+var vdevStatClasses = map[string]string{
+	"special": VdevClassSpecial,
+	"dedup":   VdevClassDedup,
+	"logs":    VdevClassLog,
+	"cache":   VdevClassCache,
+	"spare":   VdevClassSpare,
+	"spares":  VdevClassSpare,
+}
+
+// parseVdevStats parses the scripted output of zpool list -v.
+// The vdev lines start with an empty field, while the pool line does not. The vdevs do not have any indentation
+// in scripted mode: the top-level vdevs are told from their devices by their allocated space, which only they report,
+// as the devices report their physical size as well.
+// The allocation classes are introduced by header lines which are not tab separated.
+// The devices of the cache and spare classes are not part of a top-level vdev and have no parent.
+func parseVdevStats(out [][]string) ([]VdevStat, error) {
+	var (
+		stats         []VdevStat
+		class, parent string
+	)
+	for _, line := range out {
+		if len(line) == 1 {
+			fields := strings.Fields(line[0])
+			if len(fields) == 0 {
+				continue
+			}
+			c, ok := vdevStatClasses[fields[0]]
+			if !ok {
+				return nil, fmt.Errorf("unknown vdev class %q", fields[0])
+			}
+			class, parent = c, ""
+			continue
+		}
+		if line[0] != "" {
+			// the pool itself
+			continue
+		}
+		// name, size, alloc, free, ckpoint, expandsz, frag, cap, dedup, health
+		if len(line) < 11 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		s := VdevStat{Name: line[1], Class: class, Health: line[10]}
+		if err := setUint(&s.Size, line[2]); err != nil {
+			return nil, err
+		}
+		if err := setUint(&s.Allocated, line[3]); err != nil {
+			return nil, err
+		}
+		if err := setUint(&s.Free, line[4]); err != nil {
+			return nil, err
+		}
+		if err := setUint(&s.Fragmentation, strings.TrimSuffix(line[7], "%")); err != nil {
+			return nil, err
+		}
+		if err := setUint(&s.Capacity, strings.TrimSuffix(line[8], "%")); err != nil {
+			return nil, err
+		}
+		if line[3] != "-" {
+			parent = s.Name
+		} else {
+			s.Parent = parent
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
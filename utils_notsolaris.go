@@ -7,12 +7,18 @@ import "strings"
 
 var (
 	// List of ZFS properties to retrieve from zfs list command on a non-Solaris platform.
-	dsPropList = []string{"name", "origin", "used", "avail", "mountpoint", "compression", "type", "volsize", "quota", "referenced", "written", "logicalused", "usedbydataset"}
+	dsPropList = []string{"name", "origin", "used", "available", "mountpoint", "mounted", "compression", "type", "volsize", "quota", "referenced", "written", "logicalused", "usedbydataset", "refquota", "refreservation", "usedbysnapshots", "usedbychildren", "usedbyrefreservation", "logicalreferenced", "compressratio", "refcompressratio", "createtxg", "objsetid", "guid", "encryption", "keystatus", "encryptionroot", "creation"}
 
 	dsPropListOptions = strings.Join(dsPropList, ",")
 
+	// List of ZFS properties to retrieve from zfs list command on zfs versions before 0.8, which do not know the
+	// objsetid and encryption properties and fail to list them.
+	dsPropListLegacy = []string{"name", "origin", "used", "available", "mountpoint", "mounted", "compression", "type", "volsize", "quota", "referenced", "written", "logicalused", "usedbydataset", "refquota", "refreservation", "usedbysnapshots", "usedbychildren", "usedbyrefreservation", "logicalreferenced", "compressratio", "refcompressratio", "createtxg", "guid", "creation"}
+
+	dsPropListLegacyOptions = strings.Join(dsPropListLegacy, ",")
+
 	// List of Zpool properties to retrieve from zpool list command on a non-Solaris platform.
-	zpoolPropList = []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio", "fragmentation", "freeing", "leaked"}
+	zpoolPropList = []string{"name", "guid", "health", "allocated", "size", "free", "readonly", "dedupratio", "fragmentation", "freeing", "leaked", "ashift", "autoexpand", "autoreplace", "autotrim", "cachefile", "bootfs"}
 
 	zpoolPropListOptions = strings.Join(zpoolPropList, ",")
 	zpoolArgs            = []string{"get", "-Hp", zpoolPropListOptions}
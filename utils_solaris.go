@@ -7,12 +7,16 @@ import "strings"
 
 var (
 	// List of ZFS properties to retrieve from zfs list command on a Solaris platform
-	dsPropList = []string{"name", "origin", "used", "avail", "mountpoint", "compression", "type", "volsize", "quota", "referenced"}
+	dsPropList = []string{"name", "origin", "used", "available", "mountpoint", "mounted", "compression", "type", "volsize", "quota", "referenced", "creation"}
 
 	dsPropListOptions = strings.Join(dsPropList, ",")
 
+	// All the properties are known on Solaris
+	dsPropListLegacy        = dsPropList
+	dsPropListLegacyOptions = dsPropListOptions
+
 	// List of Zpool properties to retrieve from zpool list command on a non-Solaris platform
-	zpoolPropList = []string{"name", "health", "allocated", "size", "free", "readonly", "dedupratio"}
+	zpoolPropList = []string{"name", "guid", "health", "allocated", "size", "free", "readonly", "dedupratio", "autoexpand", "autoreplace", "cachefile", "bootfs"}
 
 	zpoolPropListOptions = strings.Join(zpoolPropList, ",")
 	zpoolArgs            = []string{"get", "-Hp", zpoolPropListOptions}
@@ -1,7 +1,10 @@
 package zfs
 
 import (
+	"errors"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,13 +29,619 @@ func TestParseLine(t *testing.T) {
 			value: "-",
 			want:  Zpool{Fragmentation: 0},
 		},
+		"suspended health": {
+			prop:  "health",
+			value: "SUSPENDED",
+			want:  Zpool{Health: ZpoolSuspended},
+		},
+		"dedupratio": {
+			prop:  "dedupratio",
+			value: "1.50x",
+			want:  Zpool{DedupRatio: 1.5},
+		},
+		"unavailable dedupratio": {
+			prop:  "dedupratio",
+			value: "-",
+			want:  Zpool{DedupRatio: 0},
+		},
+		"guid": {
+			prop:  "guid",
+			value: "13790218470830287398",
+			want:  Zpool{GUID: 13790218470830287398},
+		},
+		"ashift": {
+			prop:  "ashift",
+			value: "12",
+			want:  Zpool{Ashift: 12},
+		},
+		"autoexpand": {
+			prop:  "autoexpand",
+			value: "on",
+			want:  Zpool{Autoexpand: true},
+		},
+		"autotrim": {
+			prop:  "autotrim",
+			value: "off",
+			want:  Zpool{},
+		},
+		"bootfs": {
+			prop:  "bootfs",
+			value: "rpool/ROOT/default",
+			want:  Zpool{Bootfs: "rpool/ROOT/default"},
+		},
+		"default cachefile": {
+			prop:  "cachefile",
+			value: "-",
+			want:  Zpool{},
+		},
+		"unavailable size": {
+			prop:  "size",
+			value: "-",
+			want:  Zpool{Size: 0},
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			got := Zpool{}
-			got.parseLine([]string{"", test.prop, test.value})
+			if err := got.parseLine([]string{"", test.prop, test.value}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.IsSet(test.prop) != (test.value != "-") {
+				t.Fatalf("IsSet(%q) does not match value %q", test.prop, test.value)
+			}
+			got.props = nil
 			if !reflect.DeepEqual(test.want, got) {
 				t.Fatalf("parse failure: wanted: %v, got: %v", test.want, got)
 			}
 		})
 	}
 }
+
+func TestUserPropertyName(t *testing.T) {
+	for name, test := range map[string]struct {
+		module string
+		name   string
+		want   string
+		err    bool
+	}{
+		"valid":            {module: "com.example", name: "last-backup_1", want: "com.example:last-backup_1"},
+		"colon in name":    {module: "com.example", name: "backup:last", want: "com.example:backup:last"},
+		"empty module":     {name: "lastbackup", err: true},
+		"empty name":       {module: "com.example", err: true},
+		"colon in module":  {module: "com:example", name: "lastbackup", err: true},
+		"uppercase":        {module: "com.example", name: "LastBackup", err: true},
+		"space":            {module: "com.example", name: "last backup", err: true},
+		"too long":         {module: "com.example", name: strings.Repeat("a", 250), err: true},
+		"invalid module":   {module: "com/example", name: "lastbackup", err: true},
+		"plus not allowed": {module: "com.example", name: "a+b", err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := userPropertyName(test.module, test.name)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != test.want {
+				t.Fatalf("wanted: %q, got: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestVdevsArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		vdevs []VdevSpec
+		want  []string
+		err   bool
+	}{
+		"stripe": {
+			vdevs: []VdevSpec{{Devices: []string{"sda", "sdb"}}},
+			want:  []string{"sda", "sdb"},
+		},
+		"mirrors": {
+			vdevs: []VdevSpec{{Type: VdevMirror, Devices: []string{"sda", "sdb"}}, {Type: VdevMirror, Devices: []string{"sdc", "sdd"}}},
+			want:  []string{"mirror", "sda", "sdb", "mirror", "sdc", "sdd"},
+		},
+		"mirror and raidz": {
+			vdevs: []VdevSpec{{Type: VdevMirror, Devices: []string{"a", "b"}}, {Type: VdevRaidz1, Devices: []string{"c", "d", "e"}}},
+			want:  []string{"mirror", "a", "b", "raidz1", "c", "d", "e"},
+		},
+		"classes": {
+			vdevs: []VdevSpec{
+				{Class: VdevClassCache, Devices: []string{"nvme2"}},
+				{Class: VdevClassLog, Type: VdevMirror, Devices: []string{"nvme0", "nvme1"}},
+				{Type: VdevRaidz2, Devices: []string{"a", "b", "c", "d"}},
+				{Class: VdevClassSpecial, Type: VdevMirror, Devices: []string{"ssd0", "ssd1"}},
+				{Class: VdevClassSpare, Devices: []string{"e"}},
+				{Class: VdevClassCache, Devices: []string{"nvme3"}},
+			},
+			want: []string{"raidz2", "a", "b", "c", "d", "special", "mirror", "ssd0", "ssd1", "log", "mirror", "nvme0", "nvme1", "cache", "nvme2", "nvme3", "spare", "e"},
+		},
+		"log only": {
+			vdevs: []VdevSpec{{Class: VdevClassLog, Devices: []string{"nvme0"}}},
+			want:  []string{"log", "nvme0"},
+		},
+		"raidz log":      {vdevs: []VdevSpec{{Class: VdevClassLog, Type: VdevRaidz, Devices: []string{"a", "b", "c"}}}, err: true},
+		"mirrored cache": {vdevs: []VdevSpec{{Class: VdevClassCache, Type: VdevMirror, Devices: []string{"a", "b"}}}, err: true},
+		"unknown class":  {vdevs: []VdevSpec{{Class: "slog", Devices: []string{"a"}}}, err: true},
+		"no vdevs":       {err: true},
+		"empty stripe":   {vdevs: []VdevSpec{{}}, err: true},
+		"empty mirror":   {vdevs: []VdevSpec{{Type: VdevMirror}}, err: true},
+		"one-way mirror": {vdevs: []VdevSpec{{Type: VdevMirror, Devices: []string{"sda"}}}, err: true},
+		"small raidz3":   {vdevs: []VdevSpec{{Type: VdevRaidz3, Devices: []string{"a", "b", "c"}}}, err: true},
+		"unknown type":   {vdevs: []VdevSpec{{Type: "raidz4", Devices: []string{"a", "b", "c", "d", "e"}}}, err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := vdevsArgs(test.vdevs)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestUsableSpace(t *testing.T) {
+	for name, test := range map[string]struct {
+		ds   Dataset
+		want uint64
+	}{
+		"avail only":         {ds: Dataset{Avail: 100, Used: 50}, want: 100},
+		"quota":              {ds: Dataset{Avail: 100, Used: 50, Quota: 80}, want: 30},
+		"quota above avail":  {ds: Dataset{Avail: 100, Used: 50, Quota: 500}, want: 100},
+		"refquota":           {ds: Dataset{Avail: 100, Used: 50, Referenced: 20, Refquota: 40}, want: 20},
+		"quota and refquota": {ds: Dataset{Avail: 100, Used: 50, Quota: 70, Referenced: 20, Refquota: 60}, want: 20},
+		"over quota":         {ds: Dataset{Avail: 0, Used: 90, Quota: 80}, want: 0},
+		"over refquota":      {ds: Dataset{Avail: 10, Referenced: 90, Refquota: 80}, want: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := test.ds.UsableSpace(); got != test.want {
+				t.Fatalf("wanted: %d, got: %d", test.want, got)
+			}
+		})
+	}
+}
+
+func TestListOptionsArgs(t *testing.T) {
+	for name, test := range map[string]struct {
+		opts ListOptions
+		want []string
+	}{
+		"default":    {want: []string{"list", "-r", "-Hp", "-t", "all", "-o", dsPropListOptions}},
+		"depth":      {opts: ListOptions{Depth: 1}, want: []string{"list", "-d", "1", "-Hp", "-t", "all", "-o", dsPropListOptions}},
+		"types":      {opts: ListOptions{Types: []string{DatasetFilesystem, DatasetVolume}}, want: []string{"list", "-r", "-Hp", "-t", "filesystem,volume", "-o", dsPropListOptions}},
+		"sort":       {opts: ListOptions{SortBy: "creation"}, want: []string{"list", "-r", "-s", "creation", "-Hp", "-t", "all", "-o", dsPropListOptions}},
+		"sort desc":  {opts: ListOptions{SortBy: "used", SortDescending: true}, want: []string{"list", "-r", "-S", "used", "-Hp", "-t", "all", "-o", dsPropListOptions}},
+		"desc alone": {opts: ListOptions{SortDescending: true}, want: []string{"list", "-r", "-Hp", "-t", "all", "-o", dsPropListOptions}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := test.opts.args(); !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %v, got: %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDatasetIsSet(t *testing.T) {
+	d := &Dataset{props: map[string]string{
+		"available":  "-",
+		"used":       "0",
+		"volsize":    "-",
+		"quota":      "0",
+		"referenced": "1024",
+	}}
+	for prop, want := range map[string]bool{
+		"available":  false,
+		"avail":      false,
+		"used":       true,
+		"volsize":    false,
+		"quota":      true,
+		"referenced": true,
+		"REFER":      true,
+		"unknown":    false,
+	} {
+		if got := d.IsSet(prop); got != want {
+			t.Errorf("IsSet(%q): wanted: %v, got: %v", prop, want, got)
+		}
+	}
+}
+
+func TestSetRatio(t *testing.T) {
+	for value, want := range map[string]float64{
+		"1.00x": 1,
+		"2.35x": 2.35,
+		"1.5":   1.5,
+		"-":     0,
+	} {
+		var got float64
+		if err := setRatio(&got, value); err != nil {
+			t.Fatalf("unexpected error for %q: %v", value, err)
+		}
+		if got != want {
+			t.Errorf("setRatio(%q): wanted: %v, got: %v", value, want, got)
+		}
+	}
+	var got float64
+	if err := setRatio(&got, "none"); err == nil {
+		t.Fatal("expected error for an invalid ratio")
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	for name, test := range map[string]struct {
+		stdout string
+		want   [][]string
+	}{
+		"empty": {
+			stdout: "",
+			want:   nil,
+		},
+		"whitespace only": {
+			stdout: " \t\n\n  \n",
+			want:   nil,
+		},
+		"single column": {
+			stdout: "test\ntest/fs\n",
+			want:   [][]string{{"test"}, {"test/fs"}},
+		},
+		"no trailing newline": {
+			stdout: "test\tused\t1024",
+			want:   [][]string{{"test", "used", "1024"}},
+		},
+		"blank lines": {
+			stdout: "a\t1\n\nb\t2\n",
+			want:   [][]string{{"a", "1"}, {"b", "2"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := parseOutput(test.stdout); !reflect.DeepEqual(test.want, got) {
+				t.Fatalf("wanted: %q, got: %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestListEmptyOutput(t *testing.T) {
+	z := &zfs{exec: &recordExec{stdout: ""}, logger: &defaultLogger{}}
+	datasets, err := z.Datasets("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(datasets) != 0 {
+		t.Fatalf("wanted no datasets, got: %v", datasets)
+	}
+}
+
+// testDatasetLine builds a zfs list output line of a filesystem with the given name and mountpoint.
+func testDatasetLine(name, mountpoint string) string {
+	values := make([]string, len(dsPropList))
+	for i, p := range dsPropList {
+		switch p {
+		case "name":
+			values[i] = name
+		case "type":
+			values[i] = DatasetFilesystem
+		case "mountpoint":
+			values[i] = mountpoint
+		case "mounted":
+			values[i] = "yes"
+		case "origin", "compression", "encryption", "keystatus", "encryptionroot":
+			values[i] = "-"
+		case "compressratio", "refcompressratio":
+			values[i] = "1.00x"
+		default:
+			values[i] = "1024"
+		}
+	}
+	return strings.Join(values, "\t") + "\n"
+}
+
+func TestListValuesWithSpaces(t *testing.T) {
+	line := testDatasetLine("test/my data", "/mnt/my data")
+	z := &zfs{exec: &recordExec{stdout: line + line}, logger: &defaultLogger{}}
+	datasets, err := z.Datasets("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(datasets) != 2 {
+		t.Fatalf("wanted 2 datasets, got: %d", len(datasets))
+	}
+	d := datasets[0]
+	if d.Name != "test/my data" || d.Mountpoint != "/mnt/my data" {
+		t.Fatalf("wanted: %q mounted on %q, got: %q mounted on %q", "test/my data", "/mnt/my data", d.Name, d.Mountpoint)
+	}
+	if !d.Mounted || d.Used != 1024 || d.Compression != "" {
+		t.Fatalf("unexpected fields: %+v", d)
+	}
+}
+
+func TestListLegacyProperties(t *testing.T) {
+	if dsPropListLegacyOptions == dsPropListOptions {
+		t.Skip("all the properties are known on this platform")
+	}
+	values := make(map[string]string)
+	fields := strings.Split(strings.TrimSuffix(testDatasetLine("test/fs", "/test/fs"), "\n"), "\t")
+	for i, p := range dsPropList {
+		values[p] = fields[i]
+	}
+	var legacy []string
+	for _, p := range dsPropListLegacy {
+		legacy = append(legacy, values[p])
+	}
+	line := strings.Join(legacy, "\t") + "\n"
+	newExec := func() *recordExec {
+		return &recordExec{
+			outputs: map[string]string{
+				"zfs list -Hp -o " + dsPropListLegacyOptions + " test/fs":           line,
+				"zfs list -r -Hp -t all -o " + dsPropListLegacyOptions + " test/fs": line,
+			},
+			stderr:   "bad property list: invalid property 'objsetid'\n",
+			err:      errors.New("exit status 2"),
+			fail:     "list",
+			failures: 1,
+		}
+	}
+
+	exec := newExec()
+	z := &zfs{exec: exec, logger: &defaultLogger{}}
+	d, err := z.GetDataset("test/fs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Name != "test/fs" || d.Used != 1024 || d.ObjsetID != 0 || d.Encryption != "" {
+		t.Fatalf("unexpected fields: %+v", d)
+	}
+	if len(exec.lines) != 2 {
+		t.Fatalf("wanted the listing to be retried once, got: %q", exec.lines)
+	}
+
+	z = &zfs{exec: newExec(), logger: &defaultLogger{}}
+	datasets, err := z.Datasets("test/fs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(datasets) != 1 || datasets[0].Name != "test/fs" {
+		t.Fatalf("wanted test/fs, got: %v", datasets)
+	}
+
+	z = &zfs{exec: &recordExec{stderr: "cannot open 'test/fs': dataset does not exist\n", err: errors.New("exit status 1")}, logger: &defaultLogger{}}
+	if _, err := z.GetDataset("test/fs"); err == nil {
+		t.Fatal("expected the other list failures to be returned")
+	} else if exec := z.exec.(*recordExec); len(exec.lines) != 1 {
+		t.Fatalf("wanted the other list failures not to be retried, got: %q", exec.lines)
+	}
+}
+
+func TestParseVdevStats(t *testing.T) {
+	// zpool list -Hpv test of a zpool made of a mirror, a device, a log device and a cache device
+	out := "test\t2122317824\t1167360\t2121150464\t-\t-\t0\t0\t1.00\tONLINE\t-\n" +
+		"\tmirror-0\t1069547520\t585728\t1068961792\t-\t-\t0\t0\t-\tONLINE\n" +
+		"\t/tmp/loop0\t1073741824\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/tmp/loop1\t1073741824\t-\t-\t-\t-\t-\t-\t-\tONLINE\n" +
+		"\t/tmp/loop2\t1052770304\t581632\t1052188672\t-\t-\t3\t1\t-\tONLINE\n" +
+		"logs                -      -      -        -         -      -      -      -  -\n" +
+		"\t/tmp/loop3\t1052770304\t0\t1052770304\t-\t-\t0\t0\t-\tONLINE\n" +
+		"cache               -      -      -        -         -      -      -      -  -\n" +
+		"\t/tmp/loop4\t1073741824\t-\t-\t-\t-\t-\t-\t-\tONLINE\n"
+	got, err := parseVdevStats(parseOutput(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []VdevStat{
+		{Name: "mirror-0", Size: 1069547520, Allocated: 585728, Free: 1068961792, Health: ZpoolOnline},
+		{Name: "/tmp/loop0", Parent: "mirror-0", Size: 1073741824, Health: ZpoolOnline},
+		{Name: "/tmp/loop1", Parent: "mirror-0", Size: 1073741824, Health: ZpoolOnline},
+		{Name: "/tmp/loop2", Size: 1052770304, Allocated: 581632, Free: 1052188672, Fragmentation: 3, Capacity: 1, Health: ZpoolOnline},
+		{Name: "/tmp/loop3", Class: VdevClassLog, Size: 1052770304, Free: 1052770304, Health: ZpoolOnline},
+		{Name: "/tmp/loop4", Class: VdevClassCache, Size: 1073741824, Health: ZpoolOnline},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %+v, got: %+v", want, got)
+	}
+
+	if _, err := parseVdevStats([][]string{{"unknown   -   -"}}); err == nil {
+		t.Fatal("expected error for an unknown vdev class")
+	}
+}
+
+func TestPropsSlice(t *testing.T) {
+	props := map[string]string{
+		"mountpoint":         "/mnt/data",
+		"compression":        "lz4",
+		"com.example:reason": "backup",
+		"atime":              "off",
+	}
+	want := []string{"-o", "atime=off", "-o", "com.example:reason=backup", "-o", "compression=lz4", "-o", "mountpoint=/mnt/data"}
+	for i := 0; i < 10; i++ {
+		if got := propsSlice(props); !reflect.DeepEqual(want, got) {
+			t.Fatalf("wanted: %v, got: %v", want, got)
+		}
+	}
+	if got := propsSlice(nil); len(got) != 0 {
+		t.Fatalf("wanted no arguments, got: %v", got)
+	}
+}
+
+func TestCreateCommandLines(t *testing.T) {
+	props := map[string]string{
+		"recordsize":  "1M",
+		"compression": "zstd",
+		"atime":       "off",
+		"com.foo:bar": "baz",
+	}
+	outputs := map[string]string{
+		"zfs list test/vol":   testDatasetLine("test/vol", "-"),
+		"zfs list test/fs":    testDatasetLine("test/fs", "/test/fs"),
+		"zfs list test/clone": testDatasetLine("test/clone", "/test/clone"),
+	}
+	for name, test := range map[string]struct {
+		create func(z *zfs) error
+		want   string
+	}{
+		"pool": {
+			create: func(z *zfs) error {
+				_, err := z.CreateZpool("test", props, "/dev/sda")
+				return err
+			},
+			want: "zpool create -o atime=off -o com.foo:bar=baz -o compression=zstd -o recordsize=1M test /dev/sda",
+		},
+		"volume": {
+			create: func(z *zfs) error {
+				_, err := z.CreateVolume("test/vol", 1024, props)
+				return err
+			},
+			want: "zfs create -p -V 1024 -o atime=off -o com.foo:bar=baz -o compression=zstd -o recordsize=1M test/vol",
+		},
+		"filesystem": {
+			create: func(z *zfs) error {
+				_, err := z.CreateFilesystem("test/fs", props)
+				return err
+			},
+			want: "zfs create -o atime=off -o com.foo:bar=baz -o compression=zstd -o recordsize=1M test/fs",
+		},
+		"clone": {
+			create: func(z *zfs) error {
+				s := &Dataset{z: z, Name: "test/fs@snap", Type: DatasetSnapshot}
+				_, err := s.Clone("test/clone", props)
+				return err
+			},
+			want: "zfs clone -p -o atime=off -o com.foo:bar=baz -o compression=zstd -o recordsize=1M test/fs@snap test/clone",
+		},
+		"unmounted clone": {
+			create: func(z *zfs) error {
+				s := &Dataset{z: z, Name: "test/fs@snap", Type: DatasetSnapshot}
+				_, err := s.CloneUnmounted("test/clone", props)
+				return err
+			},
+			want: "zfs clone -p -o atime=off -o canmount=noauto -o com.foo:bar=baz -o compression=zstd -o recordsize=1M test/fs@snap test/clone",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				h := &recordExec{outputs: outputs}
+				if err := test.create(&zfs{exec: h, logger: &defaultLogger{}}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if h.lines[0] != test.want {
+					t.Fatalf("wanted: %s, got: %s", test.want, h.lines[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRunLines(t *testing.T) {
+	e := &recordExec{stdout: "a\t1\n\nb\t2\nc\t3\nd\t4\n"}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+
+	var got [][]string
+	if err := z.runLines(func(line []string) error {
+		got = append(got, line)
+		return nil
+	}, "zfs", "list"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := [][]string{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("wanted: %v, got: %v", want, got)
+	}
+
+	stop := errors.New("stop")
+	e.written = 0
+	err := z.runLines(func(line []string) error {
+		if line[0] == "b" {
+			return stop
+		}
+		return nil
+	}, "zfs", "list")
+	if err != stop {
+		t.Fatalf("wanted: %v, got: %v", stop, err)
+	}
+	if e.written == 5 {
+		t.Fatal("the command should have been stopped")
+	}
+}
+
+func TestPipe(t *testing.T) {
+	writeErr, readErr := errors.New("write failed"), errors.New("read failed")
+	for name, test := range map[string]struct {
+		write func(io.Writer) error
+		read  func(io.Reader) error
+		want  error
+	}{
+		"success": {
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "stream")
+				return err
+			},
+			read: func(r io.Reader) error {
+				b, err := io.ReadAll(r)
+				if err == nil && string(b) != "stream" {
+					return errors.New("unexpected stream: " + string(b))
+				}
+				return err
+			},
+		},
+		"write failure": {
+			write: func(w io.Writer) error {
+				io.WriteString(w, "str")
+				return writeErr
+			},
+			read: func(r io.Reader) error {
+				_, err := io.ReadAll(r)
+				return errors.New("truncated stream: " + err.Error())
+			},
+			want: writeErr,
+		},
+		"read failure": {
+			write: func(w io.Writer) error {
+				for {
+					if _, err := io.WriteString(w, "stream"); err != nil {
+						return err
+					}
+				}
+			},
+			read: func(r io.Reader) error {
+				return readErr
+			},
+			want: readErr,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := pipe(test.write, test.read); err != test.want {
+				t.Fatalf("wanted: %v, got: %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestListByTypeNoDatasets(t *testing.T) {
+	for name, exit := range map[string]error{"exit zero": nil, "exit non-zero": errors.New("exit status 1")} {
+		t.Run(name, func(t *testing.T) {
+			z := &zfs{exec: &recordExec{stderr: "no datasets available\n", err: exit}, logger: &defaultLogger{}}
+			snapshots, err := z.Snapshots("test/empty")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if snapshots != nil {
+				t.Fatalf("wanted no snapshots, got: %v", snapshots)
+			}
+		})
+	}
+
+	z := &zfs{exec: &recordExec{fail: "list", err: errors.New("exit status 1")}, logger: &defaultLogger{}}
+	if _, err := z.Filesystems("test"); err == nil {
+		t.Fatal("expected the other list failures to be returned")
+	}
+}
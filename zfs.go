@@ -2,18 +2,24 @@
 package zfs
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
-// ZFS dataset types, which can indicate if a dataset is a filesystem, snapshot, or volume.
+// ZFS dataset types, which can indicate if a dataset is a filesystem, snapshot, volume, or bookmark.
 const (
 	DatasetFilesystem = "filesystem"
 	DatasetSnapshot   = "snapshot"
 	DatasetVolume     = "volume"
+	DatasetBookmark   = "bookmark"
 )
 
 // Dataset is a ZFS dataset.  A dataset could be a clone, filesystem, snapshot, or volume.
@@ -21,6 +27,13 @@ const (
 //
 // The field definitions can be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+//
+// The fields of the properties which do not apply to the dataset are left to their zero value,
+// IsSet can be used to tell them from actual zeros.
+// Note that ZFS reports a quota of 0 when no quota is set.
+//
+// ZFS does not record the host which created a dataset or snapshot: the GUIDs of the datasets and of the zpool
+// are the identities which can be relied upon to match snapshots across hosts.
 type Dataset struct {
 	z             *zfs
 	Name          string
@@ -28,6 +41,7 @@ type Dataset struct {
 	Used          uint64
 	Avail         uint64
 	Mountpoint    string
+	Mounted       bool
 	Compression   string
 	Type          string
 	Written       uint64
@@ -37,7 +51,66 @@ type Dataset struct {
 	Quota         uint64
 	Referenced    uint64
 
+	// Creation is the time at which the dataset was created, to the second.
+	Creation time.Time
+
+	Refquota             uint64
+	Refreservation       uint64
+	Usedbysnapshots      uint64
+	Usedbychildren       uint64
+	Usedbyrefreservation uint64
+
+	LogicalReferenced uint64
+	CompressRatio     float64
+	RefCompressRatio  float64
+
+	// CreateTxg is the transaction group in which the dataset was created,
+	// it orders snapshots reliably even when their creation times collide.
+	CreateTxg uint64
+	ObjsetID  uint64
+	// GUID uniquely identifies the dataset and is preserved by send and receive, so that
+	// a snapshot and its replicas on other hosts have the same GUID.
+	GUID uint64
+
+	// Encryption, KeyStatus, EncryptionRoot and ObjsetID are empty or zero before zfs 0.8, which does not know them.
+	Encryption     string
+	KeyStatus      string
+	EncryptionRoot string
+
 	props map[string]string
+	// origin is the snapshot the dataset was cloned from, see OriginDataset.
+	origin *Dataset
+}
+
+// IsSet reports whether the ZFS property has a value for the receiving dataset, as of its last retrieval.
+// Properties which do not apply to the dataset, such as avail for a snapshot or volsize for a filesystem,
+// are reported by ZFS as "-": they are not set, and their parsed field is left to its zero value.
+// Both the full property names and their abbreviations may be used, e.g. "available" or "avail".
+func (d *Dataset) IsSet(property string) bool {
+	key := strings.ToLower(property)
+	for name, abbr := range dsPropAbbreviations {
+		if abbr == key {
+			key = name
+			break
+		}
+	}
+	v, ok := d.props[key]
+	return ok && v != "-" && v != ""
+}
+
+// UsableSpace returns how many more bytes can be written to the dataset.
+// It is the available space (avail), further limited by the space left under the quota (quota - used)
+// and under the reference quota (refquota - referenced) when they are set.
+// The available space already accounts for the reservations and for the quotas of the ancestors.
+func (d *Dataset) UsableSpace() uint64 {
+	usable := d.Avail
+	if d.Quota > 0 {
+		usable = minUint64(usable, subUint64(d.Quota, d.Used))
+	}
+	if d.Refquota > 0 {
+		usable = minUint64(usable, subUint64(d.Refquota, d.Referenced))
+	}
+	return usable
 }
 
 // InodeType is the type of inode as reported by Diff.
@@ -95,22 +168,51 @@ type Logger interface {
 	Log(cmd []string)
 }
 
+// ContextLogger is a Logger which also receives the context of the commands, as set with WithContext,
+// e.g. to log the request ID it carries along the command lines.
+// The LogContext method is used instead of Log when the logger implements it.
+type ContextLogger interface {
+	Logger
+	LogContext(ctx context.Context, cmd []string)
+}
+
 type defaultLogger struct{}
 
 func (*defaultLogger) Log([]string) {}
 
 type ZFS interface {
+	WithContext(ctx context.Context) ZFS
 	Datasets(filter string) ([]*Dataset, error)
+	EachDataset(filter string, fn func(*Dataset) error) error
+	ListSpace(filter string) ([]SpaceReport, error)
+	List(filter string, opts ListOptions) ([]*Dataset, error)
 	Snapshots(filter string) ([]*Dataset, error)
+	ListSnapshotsBrief(filter string) ([]SnapshotBrief, error)
+	Bookmarks(filter string) ([]*Dataset, error)
 	Filesystems(filter string) ([]*Dataset, error)
 	Volumes(filter string) ([]*Dataset, error)
+	ListFilesystems(parent string, recursive bool) ([]*Dataset, error)
+	ListVolumes(parent string, recursive bool) ([]*Dataset, error)
 	GetDataset(name string) (*Dataset, error)
+	Exists(name string) (bool, error)
+	GetPropertyMany(datasets []string, prop string) (map[string]string, error)
 	ReceiveSnapshot(input io.Reader, name string, force ...bool) (*Dataset, error)
+	Receive(input io.Reader, name string, opts ReceiveOptions) (*ReceiveResult, error)
+	ReceiveFromFile(path, name string, opts ReceiveOptions) (*ReceiveResult, error)
+	ReceiveBackup(input io.Reader, name string) (*ReceiveResult, error)
+	ResumeSend(token string, output io.Writer) error
 	CreateVolume(name string, size uint64, properties map[string]string) (*Dataset, error)
-	CreateFilesystem(name string, properties map[string]string) (*Dataset, error)
+	CreateFilesystem(name string, properties map[string]string, opts ...CreateFilesystemOptions) (*Dataset, error)
+	EnsureFilesystem(name string, properties map[string]string, reconcile bool) (*Dataset, error)
 	ListZpools() ([]*Zpool, error)
+	Capabilities() (*ZFSCapabilities, error)
+	Version() (zfsVersion, kmodVersion string, err error)
 	GetZpool(name string) (*Zpool, error)
 	CreateZpool(name string, properties map[string]string, args ...string) (*Zpool, error)
+	CreateZpoolWithVdevs(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) (*Zpool, error)
+	PlanZpool(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) ([]VdevSpec, error)
+	LabelClear(device string, force bool) error
+	DiffAgainst(snapshot, other string) ([]*InodeChange, error)
 }
 
 func New(opts ...Option) (ZFS, error) {
@@ -128,9 +230,25 @@ func New(opts ...Option) (ZFS, error) {
 }
 
 type zfs struct {
-	exec   Executor
-	sudo   bool
-	logger Logger
+	exec     Executor
+	sudo     bool
+	sudoOpts SudoOptions
+	logger   Logger
+	timeout  time.Duration
+	nice     []string
+	ionice   []string
+	dryRun   *DryRunOptions
+	ctx      context.Context
+}
+
+// WithContext returns a copy of the ZFS instance which runs its commands with ctx,
+// they are stopped when ctx is done, like with WithTimeout.
+// The datasets and zpools retrieved through the copy use ctx as well,
+// and ctx is passed to the logger if it is a ContextLogger.
+func (z *zfs) WithContext(ctx context.Context) ZFS {
+	c := *z
+	c.ctx = ctx
+	return &c
 }
 
 // do is a helper function to wrap typical calls to zfs that ignores stdout.
@@ -144,18 +262,150 @@ func (z *zfs) doOutput(arg ...string) ([][]string, error) {
 	return z.run(nil, nil, "zfs", arg...)
 }
 
+// ListOptions are the options which may be passed to List.
+type ListOptions struct {
+	// Types of the datasets to list, e.g. DatasetFilesystem, or all types if empty.
+	Types []string
+	// Depth limits the recursion, like with Children, or a depth of 0 allows unlimited recursion.
+	Depth uint64
+	// SortBy is the property used by ZFS to sort the datasets, e.g. "creation" or "used".
+	SortBy string
+	// SortDescending sorts the datasets in descending order of the SortBy property instead of ascending order.
+	SortDescending bool
+}
+
+// List returns a slice of ZFS datasets, using the given options.
+// A filter argument may be passed to select a dataset with the matching name and its descendants,
+// or empty string ("") may be used to select all datasets.
+func (z *zfs) List(filter string, opts ListOptions) ([]*Dataset, error) {
+	return z.list(filter, opts)
+}
+
 // Datasets returns a slice of ZFS datasets, regardless of type.
 // A filter argument may be passed to select a dataset with the matching name, or empty string ("") may be used to select all datasets.
 func (z *zfs) Datasets(filter string) ([]*Dataset, error) {
 	return z.listByType("all", filter)
 }
 
+// EachDataset calls fn for each ZFS dataset, regardless of type, as the datasets are listed,
+// so that the whole list is never held in memory.
+// A filter argument may be passed to select a dataset with the matching name, or empty string ("") may be used to select all datasets.
+// If fn returns an error, the listing is stopped and EachDataset returns that error.
+func (z *zfs) EachDataset(filter string, fn func(*Dataset) error) error {
+	args := ListOptions{}.args()
+	if filter != "" {
+		args = append(args, filter)
+	}
+	return z.listLines(func(line []string) error {
+		ds := &Dataset{z: z, props: make(map[string]string)}
+		if err := ds.parseProps(line); err != nil {
+			return err
+		}
+		return fn(ds)
+	}, args...)
+}
+
+// SpaceReport is the space accounting of a filesystem or volume, as listed by ListSpace.
+type SpaceReport struct {
+	Name                 string
+	Avail                uint64
+	Used                 uint64
+	Referenced           uint64
+	Usedbysnapshots      uint64
+	Usedbydataset        uint64
+	Usedbyrefreservation uint64
+	Usedbychildren       uint64
+}
+
+// spaceReportColumns are the properties listed by ListSpace, in the order of the SpaceReport fields.
+var spaceReportColumns = []string{
+	"name", "available", "used", "referenced", "usedbysnapshots", "usedbydataset", "usedbyrefreservation", "usedbychildren",
+}
+
+// ListSpace returns the space accounting of the ZFS filesystems and volumes, like zfs list -o space,
+// listing only the space properties, which is much faster than listing the datasets on large zpools.
+// A filter argument may be passed to select a dataset with the matching name and its descendants,
+// or empty string ("") may be used to select all datasets.
+func (z *zfs) ListSpace(filter string) ([]SpaceReport, error) {
+	args := []string{"list", "-r", "-Hp", "-t", DatasetFilesystem + "," + DatasetVolume, "-o", strings.Join(spaceReportColumns, ",")}
+	if filter != "" {
+		args = append(args, filter)
+	}
+	var reports []SpaceReport
+	err := z.runLines(func(line []string) error {
+		if len(line) != len(spaceReportColumns) {
+			return errors.New("output does not match what is expected on this platform")
+		}
+		r := SpaceReport{Name: line[0]}
+		for i, field := range []*uint64{&r.Avail, &r.Used, &r.Referenced, &r.Usedbysnapshots, &r.Usedbydataset, &r.Usedbyrefreservation, &r.Usedbychildren} {
+			if err := setUint(field, line[i+1]); err != nil {
+				return err
+			}
+		}
+		reports = append(reports, r)
+		return nil
+	}, "zfs", args...)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
 // Snapshots returns a slice of ZFS snapshots.
 // A filter argument may be passed to select a snapshot with the matching name, or empty string ("") may be used to select all snapshots.
 func (z *zfs) Snapshots(filter string) ([]*Dataset, error) {
 	return z.listByType(DatasetSnapshot, filter)
 }
 
+// SnapshotBrief is a lightweight description of a ZFS snapshot, as returned by ListSnapshotsBrief.
+type SnapshotBrief struct {
+	Name       string
+	Creation   time.Time
+	Used       uint64
+	Referenced uint64
+}
+
+// ListSnapshotsBrief returns a lightweight description of ZFS snapshots, only retrieving their name, creation time and space usage.
+// It is much cheaper than Snapshots when listing many snapshots.
+// A filter argument may be passed to select the snapshots of the matching dataset and its descendants,
+// or empty string ("") may be used to select all snapshots.
+func (z *zfs) ListSnapshotsBrief(filter string) ([]SnapshotBrief, error) {
+	args := []string{"list", "-Hrp", "-t", DatasetSnapshot, "-o", "name,creation,used,referenced"}
+	if filter != "" {
+		args = append(args, filter)
+	}
+	out, err := z.doOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]SnapshotBrief, 0, len(out))
+	for _, line := range out {
+		if len(line) != 4 {
+			return nil, errors.New("output does not match what is expected on this platform")
+		}
+		creation, err := strconv.ParseInt(line[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		s := SnapshotBrief{Name: line[0], Creation: time.Unix(creation, 0)}
+		if err := setUint(&s.Used, line[2]); err != nil {
+			return nil, err
+		}
+		if err := setUint(&s.Referenced, line[3]); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+// Bookmarks returns a slice of ZFS bookmarks.
+// A filter argument may be passed to select the bookmarks of the matching dataset and its descendants,
+// or empty string ("") may be used to select all bookmarks.
+func (z *zfs) Bookmarks(filter string) ([]*Dataset, error) {
+	return z.listByType(DatasetBookmark, filter)
+}
+
 // Filesystems returns a slice of ZFS filesystems.
 // A filter argument may be passed to select a filesystem with the matching name, or empty string ("") may be used to select all filesystems.
 func (z *zfs) Filesystems(filter string) ([]*Dataset, error) {
@@ -168,24 +418,112 @@ func (z *zfs) Volumes(filter string) ([]*Dataset, error) {
 	return z.listByType(DatasetVolume, filter)
 }
 
+// ListFilesystems returns the ZFS filesystems under the parent dataset, without the parent itself.
+// Only its children are returned unless recursive is set, in which case all its descendants are.
+func (z *zfs) ListFilesystems(parent string, recursive bool) ([]*Dataset, error) {
+	return z.listUnder(DatasetFilesystem, parent, recursive)
+}
+
+// ListVolumes returns the ZFS volumes under the parent dataset, e.g. all the volumes under tank/vms.
+// Only its children are returned unless recursive is set, in which case all its descendants are.
+func (z *zfs) ListVolumes(parent string, recursive bool) ([]*Dataset, error) {
+	return z.listUnder(DatasetVolume, parent, recursive)
+}
+
+func (z *zfs) listUnder(t, parent string, recursive bool) ([]*Dataset, error) {
+	if parent == "" {
+		return nil, errors.New("parent dataset name must not be empty")
+	}
+	opts := ListOptions{Types: []string{t}}
+	if !recursive {
+		opts.Depth = 1
+	}
+	datasets, err := z.list(parent, opts)
+	if err != nil {
+		return nil, err
+	}
+	under := datasets[:0]
+	for _, ds := range datasets {
+		if ds.Name != parent {
+			under = append(under, ds)
+		}
+	}
+	return under, nil
+}
+
+// Exists reports whether a dataset with the given name exists, without retrieving its properties.
+// A dataset which does not exist is reported as false with a nil error, other failures are returned.
+func (z *zfs) Exists(name string) (bool, error) {
+	if _, err := z.doOutput("list", "-H", "-o", "name", name); err != nil {
+		if errors.Is(err, ErrDatasetNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // GetDataset retrieves a single ZFS dataset by name.
 // This dataset could be any valid ZFS dataset type, such as a clone, filesystem, snapshot, or volume.
 func (z *zfs) GetDataset(name string) (*Dataset, error) {
-	out, err := z.doOutput("list", "-p", "-o", "all", name)
+	var out [][]string
+	err := z.listLines(func(line []string) error {
+		out = append(out, line)
+		return nil
+	}, "list", "-Hp", "-o", dsPropListOptions, name)
 	if err != nil {
 		return nil, err
 	}
+	if len(out) != 1 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
 
 	ds := &Dataset{z: z, Name: name, props: make(map[string]string)}
-	return ds, ds.parseProps(out)
+	return ds, ds.parseProps(out[0])
+}
+
+// Refresh reloads the receiving dataset from ZFS, updating all its fields in place.
+func (d *Dataset) Refresh() error {
+	ds, err := d.z.GetDataset(d.Name)
+	if err != nil {
+		return err
+	}
+	*d = *ds
+	return nil
+}
+
+// GetPropertyMany returns the current value of a ZFS property for each of the given datasets, using a single command.
+// The returned map is keyed by dataset name.
+// Datasets which do not exist are left out of the map instead of failing the whole batch.
+func (z *zfs) GetPropertyMany(datasets []string, prop string) (map[string]string, error) {
+	if len(datasets) == 0 {
+		return nil, nil
+	}
+	var stdout bytes.Buffer
+	args := append([]string{"get", "-Hp", "-o", "name,value", prop}, datasets...)
+	if _, err := z.run(nil, &stdout, "zfs", args...); err != nil && !onlyMissingDatasets(err) {
+		return nil, err
+	}
+	props := make(map[string]string, len(datasets))
+	for _, line := range parseOutput(stdout.String()) {
+		if len(line) < 2 {
+			continue
+		}
+		props[line[0]] = line[1]
+	}
+	return props, nil
 }
 
 // Clone clones a ZFS snapshot and returns a clone dataset.
-// An error will be returned if the input dataset is not of snapshot type.
+// The properties are passed sorted by name, so that the command line is the same for the same properties.
+// An error will be returned if the input dataset is not of snapshot type or if dest is not valid, see ValidDatasetName.
 func (d *Dataset) Clone(dest string, properties map[string]string) (*Dataset, error) {
 	if d.Type != DatasetSnapshot {
 		return nil, errors.New("can only clone snapshots")
 	}
+	if err := ValidDatasetName(dest); err != nil {
+		return nil, err
+	}
 	args := make([]string, 2, 4)
 	args[0] = "clone"
 	args[1] = "-p"
@@ -196,7 +534,116 @@ func (d *Dataset) Clone(dest string, properties map[string]string) (*Dataset, er
 	if err := d.z.do(args...); err != nil {
 		return nil, err
 	}
-	return d.z.GetDataset(dest)
+	c, err := d.z.GetDataset(dest)
+	if err != nil {
+		return nil, err
+	}
+	c.Origin, c.origin = d.Name, d
+	return c, nil
+}
+
+// CloneTemp clones the receiving dataset to dest, mounted at mountpoint, for use as an ephemeral workspace.
+// A filesystem or volume is snapshotted first, the snapshot being destroyed along with the clone.
+// The clone of a volume is not mounted, so mountpoint is ignored.
+// It returns the clone and a cleanup function which unmounts the clone if it is mounted at that time and destroys it,
+// which should be deferred right away so that the clone does not outlive the work even when it fails.
+func (d *Dataset) CloneTemp(dest, mountpoint string) (*Dataset, func() error, error) {
+	snap := d
+	if d.Type != DatasetSnapshot {
+		var err error
+		if snap, err = d.Snapshot("tmp-"+uuid.New().String(), false); err != nil {
+			return nil, nil, err
+		}
+	}
+	destroySnap := func() error {
+		if snap == d {
+			return nil
+		}
+		return snap.Destroy(DestroyDefault)
+	}
+	var props map[string]string
+	if d.Type != DatasetVolume && snap.Volsize == 0 {
+		props = map[string]string{"mountpoint": mountpoint}
+	}
+	c, err := snap.Clone(dest, props)
+	if err != nil {
+		if derr := destroySnap(); derr != nil {
+			return nil, nil, fmt.Errorf("%w (and failed to destroy the snapshot %s: %v)", err, snap.Name, derr)
+		}
+		return nil, nil, err
+	}
+	cleanup := func() error {
+		if c.Type == DatasetFilesystem {
+			mounted, err := c.IsMounted()
+			if err != nil {
+				return err
+			}
+			if mounted {
+				if _, err := c.Unmount(false); err != nil {
+					return err
+				}
+			}
+		}
+		if err := c.Destroy(DestroyDefault); err != nil {
+			return err
+		}
+		return destroySnap()
+	}
+	return c, cleanup, nil
+}
+
+// CloneUnmounted clones a ZFS snapshot like Clone, but creates the clone with canmount=noauto so that it is not mounted.
+// The clone can later be mounted explicitly with Mount.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) CloneUnmounted(dest string, properties map[string]string) (*Dataset, error) {
+	props := make(map[string]string, len(properties)+1)
+	for k, v := range properties {
+		props[k] = v
+	}
+	props["canmount"] = "noauto"
+	return d.Clone(dest, props)
+}
+
+// Clones returns the clones created from the receiving snapshot, as listed by its clones property.
+// An error will be returned if the input dataset is not of snapshot type.
+func (d *Dataset) Clones() ([]*Dataset, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only list clones of snapshots")
+	}
+	out, err := d.z.doOutput("get", "-Hp", "-o", "value", "clones", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 || len(out[0]) == 0 || out[0][0] == "-" {
+		return nil, nil
+	}
+	var clones []*Dataset
+	for _, name := range strings.Split(out[0][0], ",") {
+		c, err := d.z.GetDataset(name)
+		if err != nil {
+			return nil, err
+		}
+		clones = append(clones, c)
+	}
+	return clones, nil
+}
+
+// OriginDataset returns the snapshot the receiving clone was created from.
+// For the clones returned by Clone, it is the snapshot Clone was called on, otherwise it is retrieved from ZFS.
+// Nil is returned without error if the dataset is not a clone.
+func (d *Dataset) OriginDataset() (*Dataset, error) {
+	if d.Origin == "" {
+		return nil, nil
+	}
+	if d.origin != nil && d.origin.Name == d.Origin {
+		return d.origin, nil
+	}
+	origin, err := d.z.GetDataset(d.Origin)
+	if err != nil {
+		return nil, err
+	}
+	d.origin = origin
+	return origin, nil
 }
 
 // Unmount unmounts currently mounted ZFS file systems.
@@ -218,17 +665,40 @@ func (d *Dataset) Unmount(force bool) (*Dataset, error) {
 
 // Mount mounts ZFS file systems.
 func (d *Dataset) Mount(overlay bool, options []string) (*Dataset, error) {
+	return d.MountWithOptions(MountOptions{Overlay: overlay, Options: options})
+}
+
+// MountOptions are the options which may be passed to MountWithOptions.
+type MountOptions struct {
+	// Overlay allows mounting over a non-empty directory (zfs mount -O).
+	Overlay bool
+	// Options are temporary mount options, e.g. ro (zfs mount -o).
+	Options []string
+	// LoadKey loads the encryption key of an encrypted filesystem before mounting it (zfs mount -l),
+	// e.g. to unlock and mount an encrypted home directory in one step.
+	LoadKey bool
+}
+
+// MountWithOptions mounts the receiving ZFS file system, using the given options.
+// An error will be returned if LoadKey is set and the file system is not encrypted.
+func (d *Dataset) MountWithOptions(opts MountOptions) (*Dataset, error) {
 	if d.Type == DatasetSnapshot {
 		return nil, errors.New("cannot mount snapshots")
 	}
-	args := make([]string, 1, 5)
+	args := make([]string, 1, 6)
 	args[0] = "mount"
-	if overlay {
+	if opts.LoadKey {
+		if d.Encryption == "" || d.Encryption == "off" {
+			return nil, fmt.Errorf("cannot load the key of %s: it is not encrypted", d.Name)
+		}
+		args = append(args, "-l")
+	}
+	if opts.Overlay {
 		args = append(args, "-O")
 	}
-	if options != nil {
+	if opts.Options != nil {
 		args = append(args, "-o")
-		args = append(args, strings.Join(options, ","))
+		args = append(args, strings.Join(opts.Options, ","))
 	}
 	args = append(args, d.Name)
 	if err := d.z.do(args...); err != nil {
@@ -237,6 +707,56 @@ func (d *Dataset) Mount(overlay bool, options []string) (*Dataset, error) {
 	return d.z.GetDataset(d.Name)
 }
 
+// IsMounted reports whether the receiving filesystem is currently mounted, as read from ZFS at the time of the call.
+// Unlike the Mounted field, which is only the state when the dataset was listed, it reflects the runtime state,
+// e.g. to decide whether to unmount the filesystem before destroying it.
+func (d *Dataset) IsMounted() (bool, error) {
+	out, err := d.z.doOutput("get", "-Hp", "-o", "value", "mounted", d.Name)
+	if err != nil {
+		return false, err
+	}
+	if len(out) == 0 {
+		return false, fmt.Errorf("unexpected output getting property mounted of %s", d.Name)
+	}
+	return out[0][0] == "yes", nil
+}
+
+// ActualMountpoint returns the path where the receiving filesystem is currently mounted, or an empty string when it is not mounted.
+// Unlike the Mountpoint field, which is the configured mountpoint property as of the last retrieval, it reflects the runtime state:
+// a filesystem with canmount=off is not mounted at its mountpoint, and one with mountpoint=legacy may be mounted anywhere,
+// in which case the path is looked up in the filesystems listed by zfs mount.
+func (d *Dataset) ActualMountpoint() (string, error) {
+	out, err := d.z.doOutput("get", "-Hp", "-o", "value", "mounted,mountpoint", d.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 2 || len(out[0]) == 0 || len(out[1]) == 0 {
+		return "", fmt.Errorf("unexpected output getting properties mounted and mountpoint of %s", d.Name)
+	}
+	if out[0][0] != "yes" {
+		return "", nil
+	}
+	if out[1][0] != "legacy" {
+		return out[1][0], nil
+	}
+	mounts, err := d.z.doOutput("mount")
+	if err != nil {
+		return "", err
+	}
+	// the lines are the name and the absolute mountpoint separated by spaces, both of which may contain spaces
+	for _, line := range mounts {
+		l := strings.TrimSpace(strings.Join(line, "\t"))
+		if !strings.HasPrefix(l, d.Name) {
+			continue
+		}
+		rest := l[len(d.Name):]
+		if mountpoint := strings.TrimLeft(rest, " \t"); len(mountpoint) < len(rest) && strings.HasPrefix(mountpoint, "/") {
+			return mountpoint, nil
+		}
+	}
+	return "", fmt.Errorf("mountpoint of %s not found in the mounted filesystems", d.Name)
+}
+
 // ReceiveSnapshot receives a ZFS stream from the input io.Reader.
 // A new snapshot is created with the specified name, and streams the input data into the newly-created snapshot.
 func (z *zfs) ReceiveSnapshot(input io.Reader, name string, force ...bool) (*Dataset, error) {
@@ -262,20 +782,154 @@ func (d *Dataset) SendSnapshot(output io.Writer) error {
 }
 
 // IncrementalSend sends a ZFS stream of a snapshot to the input io.Writer using the baseSnapshot as the starting point.
-// An error will be returned if the input dataset is not of snapshot type.
+// Both snapshots are first checked to exist, with a `zfs get guid` of their names, before the send is run.
+// An error will be returned if the input dataset is not of snapshot type,
+// and an error wrapping ErrBaseSnapshotMissing if the base snapshot does not exist anymore.
 func (d *Dataset) IncrementalSend(baseSnapshot *Dataset, output io.Writer) error {
 	if d.Type != DatasetSnapshot || baseSnapshot.Type != DatasetSnapshot {
 		return errors.New("can only send snapshots")
 	}
+	if err := d.checkIncrementalBase(baseSnapshot); err != nil {
+		return err
+	}
 	_, err := d.z.run(nil, output, "zfs", "send", "-i", baseSnapshot.Name, d.Name)
 	return err
 }
 
+// SendGUIDs returns the GUIDs a stream of the receiving snapshot sent incrementally from base would record
+// as its fromguid and toguid: ZFS sets them to the guid properties of the base and of the snapshot,
+// which a receiving host can compare with the GUIDs of its own snapshots to detect diverged replicas.
+// No send is run, the GUIDs are the ones of the datasets as of their last retrieval.
+// A nil base may be passed for a full stream, in which case fromGUID is 0.
+func (d *Dataset) SendGUIDs(base *Dataset) (fromGUID, toGUID uint64, err error) {
+	if d.Type != DatasetSnapshot {
+		return 0, 0, errors.New("can only send snapshots")
+	}
+	if base == nil {
+		return 0, d.GUID, nil
+	}
+	if base.Type != DatasetSnapshot && base.Type != DatasetBookmark {
+		return 0, 0, errors.New("can only send incrementally from snapshots or bookmarks")
+	}
+	return base.GUID, d.GUID, nil
+}
+
+// checkIncrementalBase checks that both the receiving snapshot and the base of an incremental send exist,
+// an error wrapping ErrBaseSnapshotMissing is returned if the base does not.
+func (d *Dataset) checkIncrementalBase(base *Dataset) error {
+	guids, err := d.z.GetPropertyMany([]string{base.Name, d.Name}, "guid")
+	if err != nil {
+		return err
+	}
+	if _, ok := guids[base.Name]; !ok {
+		if base.Type == DatasetSnapshot {
+			return fmt.Errorf("%s: %w, send from a bookmark of the base snapshot instead", base.Name, ErrBaseSnapshotMissing)
+		}
+		return fmt.Errorf("%s: %w", base.Name, ErrBaseSnapshotMissing)
+	}
+	if _, ok := guids[d.Name]; !ok {
+		return fmt.Errorf("snapshot %s does not exist", d.Name)
+	}
+	return nil
+}
+
+// IncrementalSendFrom sends a ZFS stream of a snapshot to the input io.Writer using base as the starting point.
+// The base may either be a snapshot or a bookmark, which allows incremental sends after the base snapshot was destroyed.
+// An error will be returned if the input dataset is not of snapshot type or if the base is neither a snapshot nor a bookmark.
+func (d *Dataset) IncrementalSendFrom(base *Dataset, output io.Writer) error {
+	if d.Type != DatasetSnapshot {
+		return errors.New("can only send snapshots")
+	}
+	if base.Type != DatasetSnapshot && base.Type != DatasetBookmark {
+		return errors.New("can only send incrementally from snapshots or bookmarks")
+	}
+	if err := d.checkIncrementalBase(base); err != nil {
+		return err
+	}
+	_, err := d.z.run(nil, output, "zfs", "send", "-i", base.Name, d.Name)
+	return err
+}
+
+// IntermediarySend sends a ZFS stream of a snapshot to the input io.Writer using base as the starting point,
+// including all the intermediate snapshots between base and the snapshot (zfs send -I), so that they are
+// received as well, which IncrementalSend does not.
+// An error will be returned if the input dataset and the base are not snapshots of the same dataset,
+// and an error wrapping ErrBaseSnapshotMissing if the base snapshot does not exist anymore.
+func (d *Dataset) IntermediarySend(base *Dataset, output io.Writer) error {
+	if d.Type != DatasetSnapshot || base.Type != DatasetSnapshot {
+		return errors.New("can only send snapshots")
+	}
+	if fs, baseFs := d.Name[:strings.Index(d.Name, "@")], base.Name[:strings.Index(base.Name, "@")]; fs != baseFs {
+		return fmt.Errorf("cannot send the intermediate snapshots from %s: not a snapshot of %s", base.Name, fs)
+	}
+	if err := d.checkIncrementalBase(base); err != nil {
+		return err
+	}
+	_, err := d.z.run(nil, output, "zfs", "send", "-I", base.Name, d.Name)
+	return err
+}
+
+// Bookmark creates a new ZFS bookmark of the receiving snapshot, using the specified name.
+// An error will be returned if the input dataset is not of snapshot type.
+//
+// More information about bookmarks may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-bookmark.8.html
+func (d *Dataset) Bookmark(name string) (*Dataset, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only bookmark snapshots")
+	}
+	if err := checkNameComponent(name); err != nil {
+		return nil, fmt.Errorf("invalid bookmark name %q: %w", name, err)
+	}
+	bookmark := fmt.Sprintf("%s#%s", d.Name[:strings.Index(d.Name, "@")], name)
+	if err := d.z.do("bookmark", d.Name, bookmark); err != nil {
+		return nil, err
+	}
+	return d.z.GetDataset(bookmark)
+}
+
+// Redact creates a redaction bookmark of the receiving snapshot, using the specified name.
+// The bookmark records the blocks modified in the redaction snapshots, which are then omitted
+// from the stream when sending with the SendOptions.RedactBookmark option.
+// An error will be returned if the input dataset or any of the redaction snapshots is not of snapshot type.
+//
+// More information about redaction may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-redact.8.html
+func (d *Dataset) Redact(bookmark string, redactionSnapshots ...*Dataset) (*Dataset, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only redact snapshots")
+	}
+	if len(redactionSnapshots) == 0 {
+		return nil, errors.New("at least one redaction snapshot is required")
+	}
+	if err := checkNameComponent(bookmark); err != nil {
+		return nil, fmt.Errorf("invalid bookmark name %q: %w", bookmark, err)
+	}
+	args := []string{"redact", d.Name, bookmark}
+	for _, s := range redactionSnapshots {
+		if s.Type != DatasetSnapshot {
+			return nil, errors.New("redaction datasets must be snapshots")
+		}
+		args = append(args, s.Name)
+	}
+	if err := d.z.do(args...); err != nil {
+		return nil, err
+	}
+	return d.z.GetDataset(fmt.Sprintf("%s#%s", d.Name[:strings.Index(d.Name, "@")], bookmark))
+}
+
 // CreateVolume creates a new ZFS volume with the specified name, size, and properties.
+// The name and the keylocation property of an encrypted volume are validated first, see ValidDatasetName and ValidKeyLocation.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 func (z *zfs) CreateVolume(name string, size uint64, properties map[string]string) (*Dataset, error) {
+	if err := ValidDatasetName(name); err != nil {
+		return nil, err
+	}
+	if err := checkKeyLocation(properties); err != nil {
+		return nil, err
+	}
 	args := make([]string, 4, 5)
 	args[0] = "create"
 	args[1] = "-p"
@@ -294,28 +948,172 @@ func (z *zfs) CreateVolume(name string, size uint64, properties map[string]strin
 // Destroy destroys a ZFS dataset.
 // If the destroy bit flag is set, any descendents of the dataset will be recursively destroyed, including snapshots.
 // If the deferred bit flag is set, the snapshot is marked for deferred deletion.
+// An error will be returned if both recursive bit flags are set, or if the deferred bit flag is set for a dataset which is not a snapshot.
 func (d *Dataset) Destroy(flags DestroyFlag) error {
-	args := make([]string, 1, 3)
-	args[0] = "destroy"
-	if flags&DestroyRecursive != 0 {
+	if err := flags.validate(d.Type == DatasetSnapshot); err != nil {
+		return err
+	}
+	args := append([]string{"destroy"}, flags.args()...)
+	args = append(args, d.Name)
+	err := d.z.do(args...)
+	return err
+}
+
+// validate returns an error if the flags are not a meaningful combination for destroying snapshots or other datasets:
+// DestroyRecursiveClones already implies DestroyRecursive, and only snapshots may be marked for deferred deletion.
+func (f DestroyFlag) validate(snapshots bool) error {
+	if f&DestroyRecursive != 0 && f&DestroyRecursiveClones != 0 {
+		return errors.New("DestroyRecursive and DestroyRecursiveClones must not be combined, DestroyRecursiveClones already destroys the descendents")
+	}
+	if f&DestroyDeferDeletion != 0 && !snapshots {
+		return errors.New("DestroyDeferDeletion only applies to snapshots")
+	}
+	return nil
+}
+
+func (f DestroyFlag) args() []string {
+	var args []string
+	if f&DestroyRecursive != 0 {
 		args = append(args, "-r")
 	}
 
-	if flags&DestroyRecursiveClones != 0 {
+	if f&DestroyRecursiveClones != 0 {
 		args = append(args, "-R")
 	}
 
-	if flags&DestroyDeferDeletion != 0 {
+	if f&DestroyDeferDeletion != 0 {
 		args = append(args, "-d")
 	}
 
-	if flags&DestroyForceUmount != 0 {
+	if f&DestroyForceUmount != 0 {
 		args = append(args, "-f")
 	}
+	return args
+}
 
-	args = append(args, d.Name)
-	err := d.z.do(args...)
-	return err
+// DestroyPlan is what a destroy would remove, as reported by ZFS without destroying anything.
+type DestroyPlan struct {
+	// Datasets are the names of the datasets, snapshots and bookmarks which would be destroyed.
+	Datasets []string
+	// Reclaim is the estimated space which would be reclaimed.
+	Reclaim uint64
+}
+
+// DestroyPlan returns what destroying the receiving dataset with the given flags would remove,
+// using a dry-run destroy (zfs destroy -nvp), e.g. to confirm the blast radius of DestroyRecursiveClones.
+func (d *Dataset) DestroyPlan(flags DestroyFlag) (*DestroyPlan, error) {
+	if err := flags.validate(d.Type == DatasetSnapshot); err != nil {
+		return nil, err
+	}
+	args := append([]string{"destroy", "-nvp"}, flags.args()...)
+	out, err := d.z.doOutput(append(args, d.Name)...)
+	if err != nil {
+		return nil, err
+	}
+	plan := &DestroyPlan{}
+	for _, line := range out {
+		if len(line) < 2 {
+			continue
+		}
+		switch line[0] {
+		case "destroy":
+			plan.Datasets = append(plan.Datasets, line[1])
+		case "reclaim":
+			if err := setUint(&plan.Reclaim, line[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+// DependentClones returns the names of the clones, outside of the receiving dataset hierarchy,
+// which depend on its snapshots and would be destroyed along with it by DestroyRecursiveClones.
+func (d *Dataset) DependentClones() ([]string, error) {
+	plan, err := d.DestroyPlan(DestroyRecursiveClones)
+	if err != nil {
+		return nil, err
+	}
+	var clones []string
+	for _, n := range plan.Datasets {
+		if n == d.Name || strings.HasPrefix(n, d.Name+"/") || strings.ContainsAny(n, "@#") {
+			continue
+		}
+		clones = append(clones, n)
+	}
+	return clones, nil
+}
+
+// DestroySnapshotRange destroys the snapshots of the receiving filesystem or volume from start to end inclusive,
+// in a single command using the `fs@start%end` syntax.
+// An empty start or end selects the range from the oldest or up to the newest snapshot.
+// An error will be returned if the dataset is a snapshot or if start was created after end.
+func (d *Dataset) DestroySnapshotRange(start, end string, flags DestroyFlag) error {
+	if d.Type != DatasetFilesystem && d.Type != DatasetVolume {
+		return errors.New("can only destroy the snapshots of filesystems and volumes")
+	}
+	if err := flags.validate(true); err != nil {
+		return err
+	}
+	for _, n := range []string{start, end} {
+		if n == "" {
+			continue
+		}
+		if err := checkNameComponent(n); err != nil {
+			return fmt.Errorf("invalid snapshot name %q: %w", n, err)
+		}
+	}
+	if start != "" && end != "" {
+		startName := fmt.Sprintf("%s@%s", d.Name, start)
+		endName := fmt.Sprintf("%s@%s", d.Name, end)
+		txgs, err := d.z.GetPropertyMany([]string{startName, endName}, "createtxg")
+		if err != nil {
+			return err
+		}
+		for _, n := range []string{startName, endName} {
+			if _, ok := txgs[n]; !ok {
+				return fmt.Errorf("snapshot %s does not exist", n)
+			}
+		}
+		startTxg, err := strconv.ParseUint(txgs[startName], 10, 64)
+		if err != nil {
+			return err
+		}
+		endTxg, err := strconv.ParseUint(txgs[endName], 10, 64)
+		if err != nil {
+			return err
+		}
+		if startTxg > endTxg {
+			return fmt.Errorf("snapshot %s was created after %s", startName, endName)
+		}
+	}
+	args := append([]string{"destroy"}, flags.args()...)
+	args = append(args, fmt.Sprintf("%s@%s%%%s", d.Name, start, end))
+	return d.z.do(args...)
+}
+
+// DestroySnapshots destroys the named snapshots of the receiving filesystem or volume atomically,
+// in a single command using the `fs@a,b,c` syntax.
+// The names are the short snapshot names, without the dataset name and the '@' separator.
+// An error will be returned if the dataset is a snapshot or if any name is not a valid snapshot name, see IsValidDatasetName.
+func (d *Dataset) DestroySnapshots(names []string, flags DestroyFlag) error {
+	if d.Type != DatasetFilesystem && d.Type != DatasetVolume {
+		return errors.New("can only destroy the snapshots of filesystems and volumes")
+	}
+	if err := flags.validate(true); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return errors.New("no snapshots to destroy")
+	}
+	for _, n := range names {
+		if err := checkNameComponent(n); err != nil {
+			return fmt.Errorf("invalid snapshot name %q: %w", n, err)
+		}
+	}
+	args := append([]string{"destroy"}, flags.args()...)
+	args = append(args, fmt.Sprintf("%s@%s", d.Name, strings.Join(names, ",")))
+	return d.z.do(args...)
 }
 
 // SetProperty sets a ZFS property on the receiving dataset.
@@ -331,6 +1129,32 @@ func (d *Dataset) SetProperty(key, val string) error {
 	return nil
 }
 
+// SetPropertyRecursive sets a ZFS property on the receiving dataset and on all its descendant filesystems and volumes.
+// As `zfs set` is not recursive, the descendants are listed with Children and the property is set on each of them in turn,
+// stopping at the first failure.
+// Snapshots and bookmarks are skipped.
+//
+// A full list of available ZFS properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+func (d *Dataset) SetPropertyRecursive(key, val string) error {
+	children, err := d.Children(0)
+	if err != nil {
+		return err
+	}
+	if err := d.SetProperty(key, val); err != nil {
+		return err
+	}
+	for _, c := range children {
+		if c.Type == DatasetSnapshot || c.Type == DatasetBookmark {
+			continue
+		}
+		if err := c.SetProperty(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SetProperties sets multiple ZFS properties on the receiving dataset.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
@@ -366,18 +1190,37 @@ func (d *Dataset) GetProperty(key string) (string, error) {
 	if v, ok := d.props[strings.ToLower(key)]; ok {
 		return v, nil
 	}
-	// custom properties does not return error
-	if strings.Contains(key, ":") {
-		return "-", nil
-	}
+	// user properties are not listed, unset ones are returned as "-" by zfs get
 	out, err := d.z.doOutput("get", "-H", "-p", key, d.Name)
 	if err != nil {
 		return "", err
 	}
+	if len(out) == 0 || len(out[0]) < 3 {
+		return "", fmt.Errorf("unexpected output getting property %s of %s", key, d.Name)
+	}
 
 	return out[0][2], nil
 }
 
+// WrittenSince returns the amount of space referenced by the receiving dataset which was written since the snapshot,
+// i.e. the size of an incremental send from the snapshot, using the written@snapshot property.
+// The snapshot may be a short snapshot name, interpreted in the filesystem of the receiving dataset,
+// or a full snapshot or bookmark name, e.g. a snapshot of the origin of a clone.
+func (d *Dataset) WrittenSince(snapshot string) (uint64, error) {
+	if snapshot == "" || strings.HasSuffix(snapshot, "@") || strings.HasSuffix(snapshot, "#") {
+		return 0, errors.New("snapshot name is required")
+	}
+	v, err := d.GetProperty("written@" + snapshot)
+	if err != nil {
+		return 0, err
+	}
+	var written uint64
+	if err := setUint(&written, v); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
 // GetProperties returns the current values of multiple ZFS properties from the receiving dataset.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
@@ -389,7 +1232,7 @@ func (d *Dataset) GetProperties(keys ...string) ([]string, error) {
 	props, failed := make([]string, 0, len(keys)), false
 	for _, v := range keys {
 		val, ok := d.props[strings.ToLower(v)]
-		if failed = !ok && !strings.Contains(v, ":"); failed {
+		if failed = !ok; failed {
 			props = make([]string, 0, len(keys))
 			break
 		}
@@ -411,6 +1254,201 @@ func (d *Dataset) GetProperties(keys ...string) ([]string, error) {
 	return props, nil
 }
 
+// Property sources, as reported by zfs get.
+const (
+	PropertySourceLocal     = "local"
+	PropertySourceDefault   = "default"
+	PropertySourceInherited = "inherited"
+	PropertySourceTemporary = "temporary"
+	PropertySourceReceived  = "received"
+	PropertySourceNone      = "none"
+)
+
+// Property is a ZFS property of a dataset, along with where its value comes from.
+type Property struct {
+	Name  string
+	Value string
+	// Source is one of the PropertySource constants.
+	Source string
+	// InheritedFrom is the dataset the value is inherited from, when Source is PropertySourceInherited.
+	InheritedFrom string
+}
+
+// GetPropertiesFiltered returns the ZFS properties of the receiving dataset whose source is one of the given sources,
+// e.g. only the locally set properties to backup the configuration of the dataset.
+// All the properties are considered when no keys are given, and all the sources when no sources are given.
+// The properties which do not apply to the dataset are left out.
+func (d *Dataset) GetPropertiesFiltered(keys, sources []string) ([]Property, error) {
+	args := []string{"get", "-Hp", "-o", "property,value,source"}
+	if len(sources) > 0 {
+		args = append(args, "-s", strings.Join(sources, ","))
+	}
+	names := "all"
+	if len(keys) > 0 {
+		names = strings.Join(keys, ",")
+	}
+	out, err := d.z.doOutput(append(args, names, d.Name)...)
+	if err != nil {
+		return nil, err
+	}
+	props := make([]Property, 0, len(out))
+	for _, line := range out {
+		if len(line) < 3 {
+			return nil, fmt.Errorf("unexpected output getting properties of %s", d.Name)
+		}
+		p := Property{Name: line[0], Value: line[1], Source: line[2]}
+		if from := strings.TrimPrefix(p.Source, PropertySourceInherited+" from "); from != p.Source {
+			p.Source, p.InheritedFrom = PropertySourceInherited, from
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// createOnlyProperties are the properties which may only be set at creation and cannot be changed with zfs set.
+var createOnlyProperties = []string{
+	"casesensitivity", "normalization", "utf8only", "volblocksize", "encryption", "keyformat", "pbkdf2iters",
+}
+
+// ExportProperties returns the locally set ZFS properties of the receiving dataset, i.e. its configuration,
+// so that it can be applied to another dataset with ApplyProperties.
+// The properties which cannot be changed once the dataset is created are left out.
+func (d *Dataset) ExportProperties() (map[string]string, error) {
+	local, err := d.GetPropertiesFiltered(nil, []string{PropertySourceLocal})
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string, len(local))
+	for _, p := range local {
+		if contains(createOnlyProperties, p.Name) {
+			continue
+		}
+		props[p.Name] = p.Value
+	}
+	return props, nil
+}
+
+// ApplyProperties sets all the given ZFS properties on the receiving dataset with a single zfs set,
+// e.g. the ones exported from another dataset with ExportProperties.
+func (d *Dataset) ApplyProperties(properties map[string]string) error {
+	keyValPairs := make([]string, 0, 2*len(properties))
+	for _, k := range sortedKeys(properties) {
+		keyValPairs = append(keyValPairs, k, properties[k])
+	}
+	return d.SetProperties(keyValPairs...)
+}
+
+// GetPropertyRecursive returns the current value of a ZFS property for the receiving dataset and all its descendants.
+// The returned map is keyed by dataset name.
+// A recursion depth may be specified, or a depth of 0 allows unlimited recursion.
+func (d *Dataset) GetPropertyRecursive(key string, depth ...uint64) (map[string]string, error) {
+	args := []string{"get", "-Hp"}
+	if len(depth) > 0 && depth[0] > 0 {
+		args = append(args, "-d", strconv.FormatUint(depth[0], 10))
+	} else {
+		args = append(args, "-r")
+	}
+	args = append(args, "-o", "name,value", key, d.Name)
+	out, err := d.z.doOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string, len(out))
+	for _, v := range out {
+		props[v[0]] = v[1]
+	}
+	return props, nil
+}
+
+// SetUserProperty sets a user-defined ZFS property named `module:name` on the receiving dataset.
+// An error is returned if the resulting property name does not follow the ZFS user property naming rules.
+//
+// More information about user properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html#User_Properties.
+func (d *Dataset) SetUserProperty(module, name, value string) error {
+	key, err := userPropertyName(module, name)
+	if err != nil {
+		return err
+	}
+	return d.SetProperty(key, value)
+}
+
+// GetUserProperty returns the current value of the user-defined ZFS property named `module:name` from the receiving dataset.
+// The value is always read from ZFS, and "-" is returned when the property is not set.
+//
+// More information about user properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html#User_Properties.
+func (d *Dataset) GetUserProperty(module, name string) (string, error) {
+	key, err := userPropertyName(module, name)
+	if err != nil {
+		return "", err
+	}
+	out, err := d.z.doOutput("get", "-H", "-p", key, d.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(out) == 0 || len(out[0]) < 3 {
+		return "", fmt.Errorf("unexpected output getting property %s of %s", key, d.Name)
+	}
+	d.props[key] = out[0][2]
+	return out[0][2], nil
+}
+
+// AutoSnapshotProperty is the conventional user property used to exclude datasets from automated snapshot tools,
+// such as zfs-auto-snapshot.
+const AutoSnapshotProperty = "com.sun:auto-snapshot"
+
+// AutoSnapshot is the state of the AutoSnapshotProperty of a dataset.
+type AutoSnapshot int
+
+// AutoSnapshot states.
+const (
+	// AutoSnapshotUnset is the state of a dataset when neither it nor any of its ancestors sets the property.
+	AutoSnapshotUnset AutoSnapshot = iota
+	AutoSnapshotOn
+	AutoSnapshotOff
+)
+
+// AutoSnapshot returns the state of the AutoSnapshotProperty of the receiving dataset,
+// which may be set on the dataset or inherited from an ancestor.
+// Values other than true and false are reported as AutoSnapshotUnset.
+func (d *Dataset) AutoSnapshot() (AutoSnapshot, error) {
+	v, err := d.GetUserProperty("com.sun", "auto-snapshot")
+	if err != nil {
+		return AutoSnapshotUnset, err
+	}
+	switch strings.ToLower(v) {
+	case "true":
+		return AutoSnapshotOn, nil
+	case "false":
+		return AutoSnapshotOff, nil
+	}
+	return AutoSnapshotUnset, nil
+}
+
+// AutoSnapshotEnabled reports whether automated snapshot tools should snapshot the receiving dataset.
+// Following the convention of these tools, a dataset is included unless the AutoSnapshotProperty is false:
+// use AutoSnapshot to tell an unset property from a true one.
+func (d *Dataset) AutoSnapshotEnabled() (bool, error) {
+	state, err := d.AutoSnapshot()
+	return state != AutoSnapshotOff, err
+}
+
+// SetAutoSnapshot sets the AutoSnapshotProperty of the receiving dataset, which is inherited by its descendants.
+func (d *Dataset) SetAutoSnapshot(enabled bool) error {
+	return d.SetUserProperty("com.sun", "auto-snapshot", strconv.FormatBool(enabled))
+}
+
+// InheritAutoSnapshot clears the AutoSnapshotProperty of the receiving dataset, so that it is inherited from its ancestors,
+// or unset if none of them sets it.
+func (d *Dataset) InheritAutoSnapshot() error {
+	if err := d.z.do("inherit", AutoSnapshotProperty, d.Name); err != nil {
+		return err
+	}
+	delete(d.props, AutoSnapshotProperty)
+	return nil
+}
+
 // GetAllProperties returns all the ZFS properties from the receiving dataset.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
@@ -429,16 +1467,45 @@ func (d *Dataset) GetAllProperties() (map[string]string, error) {
 
 // Rename renames a dataset.
 func (d *Dataset) Rename(name string, createParent, recursiveRenameSnapshots bool) (*Dataset, error) {
-	args := make([]string, 3, 5)
-	args[0] = "rename"
-	args[1] = d.Name
-	args[2] = name
-	if createParent {
+	return d.RenameWithOptions(name, RenameOptions{CreateParent: createParent, Recursive: recursiveRenameSnapshots})
+}
+
+// RenameOptions are the options which may be passed to RenameWithOptions.
+//
+// More information about the rename options may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zfs-rename.8.html
+type RenameOptions struct {
+	// CreateParent creates all the non-existing parent datasets of the new name (zfs rename -p).
+	CreateParent bool
+	// Recursive renames the snapshots of all descendent datasets, only valid for snapshots (zfs rename -r).
+	Recursive bool
+	// NoRemount does not remount the filesystem and its descendents after the rename (zfs rename -u).
+	NoRemount bool
+	// Force unmounts the filesystem even if it is busy (zfs rename -f).
+	Force bool
+}
+
+func (o RenameOptions) args() []string {
+	var args []string
+	if o.CreateParent {
 		args = append(args, "-p")
 	}
-	if recursiveRenameSnapshots {
+	if o.Recursive {
 		args = append(args, "-r")
 	}
+	if o.NoRemount {
+		args = append(args, "-u")
+	}
+	if o.Force {
+		args = append(args, "-f")
+	}
+	return args
+}
+
+// RenameWithOptions renames a dataset using the given options.
+func (d *Dataset) RenameWithOptions(name string, opts RenameOptions) (*Dataset, error) {
+	args := append([]string{"rename"}, opts.args()...)
+	args = append(args, d.Name, name)
 	if err := d.z.do(args...); err != nil {
 		return d, err
 	}
@@ -446,19 +1513,104 @@ func (d *Dataset) Rename(name string, createParent, recursiveRenameSnapshots boo
 	return d.z.GetDataset(name)
 }
 
+// RenameSnapshot renames the receiving snapshot, keeping it on the same filesystem.
+// The new name may be given either as the short name after the `@`, or as a full snapshot name of the same filesystem.
+// If recursive is set, the snapshots with the same name on all descendent filesystems are renamed as well.
+// An error will be returned if the input dataset is not of snapshot type or if the new name targets another filesystem.
+func (d *Dataset) RenameSnapshot(newShortName string, recursive bool) (*Dataset, error) {
+	if d.Type != DatasetSnapshot {
+		return nil, errors.New("can only rename snapshots")
+	}
+	fs := d.Name[:strings.Index(d.Name, "@")]
+	if i := strings.Index(newShortName, "@"); i >= 0 {
+		if newShortName[:i] != fs {
+			return nil, fmt.Errorf("cannot move snapshot %s to another filesystem: %s", d.Name, newShortName)
+		}
+		newShortName = newShortName[i+1:]
+	}
+	if err := checkNameComponent(newShortName); err != nil {
+		return nil, fmt.Errorf("invalid snapshot name %q: %w", newShortName, err)
+	}
+	args := make([]string, 1, 4)
+	args[0] = "rename"
+	if recursive {
+		args = append(args, "-r")
+	}
+	name := fs + "@" + newShortName
+	args = append(args, d.Name, name)
+	if err := d.z.do(args...); err != nil {
+		return nil, err
+	}
+	return d.z.GetDataset(name)
+}
+
 // Snapshots returns a slice of all ZFS snapshots of a given dataset.
 func (d *Dataset) Snapshots() ([]*Dataset, error) {
 	return d.z.Snapshots(d.Name)
 }
 
+// SnapshotsBetween returns the snapshots of the receiving filesystem or volume created between start and end inclusive,
+// sorted from the oldest to the newest, e.g. to apply a retention policy.
+// A zero start or end leaves the window open on that side. The snapshots of the descendent datasets are not returned.
+// An error will be returned if the dataset is a snapshot or if start is after end.
+func (d *Dataset) SnapshotsBetween(start, end time.Time) ([]*Dataset, error) {
+	if d.Type != DatasetFilesystem && d.Type != DatasetVolume {
+		return nil, errors.New("can only list the snapshots of filesystems and volumes")
+	}
+	if !start.IsZero() && !end.IsZero() && start.After(end) {
+		return nil, fmt.Errorf("start %s is after end %s", start, end)
+	}
+	// the creation times may collide, the transaction groups keep the snapshots in order
+	snapshots, err := d.z.list(d.Name, ListOptions{Depth: 1, Types: []string{DatasetSnapshot}, SortBy: "createtxg"})
+	if noDatasetsAvailable(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var between []*Dataset
+	for _, s := range snapshots {
+		if (!start.IsZero() && s.Creation.Before(start)) || (!end.IsZero() && s.Creation.After(end)) {
+			continue
+		}
+		between = append(between, s)
+	}
+	return between, nil
+}
+
+// CreateFilesystemOptions are the options which may be passed to CreateFilesystem.
+type CreateFilesystemOptions struct {
+	// CreateParents creates all the non-existing parent datasets (zfs create -p).
+	CreateParents bool
+	// NoMount does not mount the newly created filesystem (zfs create -u).
+	NoMount bool
+}
+
 // CreateFilesystem creates a new ZFS filesystem with the specified name and properties.
+// Optional CreateFilesystemOptions may be passed, e.g. to create the missing parent datasets like CreateVolume does.
+// The name and the keylocation property of an encrypted filesystem are validated first, see ValidDatasetName and ValidKeyLocation.
 //
 // A full list of available ZFS properties may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
-func (z *zfs) CreateFilesystem(name string, properties map[string]string) (*Dataset, error) {
-	args := make([]string, 1, 4)
+func (z *zfs) CreateFilesystem(name string, properties map[string]string, opts ...CreateFilesystemOptions) (*Dataset, error) {
+	if err := ValidDatasetName(name); err != nil {
+		return nil, err
+	}
+	if err := checkKeyLocation(properties); err != nil {
+		return nil, err
+	}
+	args := make([]string, 1, 6)
 	args[0] = "create"
 
+	if len(opts) > 0 {
+		if opts[0].CreateParents {
+			args = append(args, "-p")
+		}
+		if opts[0].NoMount {
+			args = append(args, "-u")
+		}
+	}
+
 	if properties != nil {
 		args = append(args, propsSlice(properties)...)
 	}
@@ -470,15 +1622,68 @@ func (z *zfs) CreateFilesystem(name string, properties map[string]string) (*Data
 	return z.GetDataset(name)
 }
 
+// EnsureFilesystem returns the ZFS filesystem with the specified name, creating it with the properties
+// and its missing parents if it does not exist, e.g. for idempotent provisioning.
+// The properties of an existing filesystem are left untouched unless reconcile is set, in which case
+// those which differ from the given values, as retrieved with GetProperty, are set on it.
+// The properties which can only be set at creation, such as encryption, cannot be reconciled and make it fail.
+// An error will be returned if a dataset with the name exists but is not a filesystem.
+func (z *zfs) EnsureFilesystem(name string, properties map[string]string, reconcile bool) (*Dataset, error) {
+	ds, err := z.GetDataset(name)
+	if errors.Is(err, ErrDatasetNotFound) {
+		return z.CreateFilesystem(name, properties, CreateFilesystemOptions{CreateParents: true})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ds.Type != DatasetFilesystem {
+		return nil, fmt.Errorf("%s exists and is a %s, not a filesystem", name, ds.Type)
+	}
+	if !reconcile || len(properties) == 0 {
+		return ds, nil
+	}
+	changed := false
+	for _, k := range sortedKeys(properties) {
+		v, err := ds.GetProperty(k)
+		if err != nil {
+			return nil, err
+		}
+		if v == properties[k] {
+			continue
+		}
+		if err := ds.SetProperty(k, properties[k]); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		return ds, nil
+	}
+	return z.GetDataset(name)
+}
+
 // Snapshot creates a new ZFS snapshot of the receiving dataset, using the specified name.
 // Optionally, the snapshot can be taken recursively, creating snapshots of all descendent filesystems in a single, atomic operation.
+// An error will be returned if the resulting snapshot name is not valid, see ValidSnapshotName.
 func (d *Dataset) Snapshot(name string, recursive bool) (*Dataset, error) {
+	return d.SnapshotWithProperties(name, recursive, nil)
+}
+
+// SnapshotWithProperties creates a new ZFS snapshot of the receiving dataset like Snapshot,
+// setting the given properties atomically with its creation, e.g. user properties such as `com.example:reason`.
+func (d *Dataset) SnapshotWithProperties(name string, recursive bool, properties map[string]string) (*Dataset, error) {
 	args := make([]string, 1, 4)
 	args[0] = "snapshot"
 	if recursive {
 		args = append(args, "-r")
 	}
+	if properties != nil {
+		args = append(args, propsSlice(properties)...)
+	}
 	snapName := fmt.Sprintf("%s@%s", d.Name, name)
+	if err := ValidSnapshotName(snapName); err != nil {
+		return nil, err
+	}
 	args = append(args, snapName)
 	if err := d.z.do(args...); err != nil {
 		return nil, err
@@ -509,38 +1714,10 @@ func (d *Dataset) Rollback(destroyMoreRecent bool) error {
 // Children returns a slice of children of the receiving ZFS dataset.
 // A recursion depth may be specified, or a depth of 0 allows unlimited recursion.
 func (d *Dataset) Children(depth uint64) ([]*Dataset, error) {
-	args := []string{"list"}
-	if depth > 0 {
-		args = append(args, "-d")
-		args = append(args, strconv.FormatUint(depth, 10))
-	} else {
-		args = append(args, "-r")
-	}
-	args = append(args, "-t", "all", "-p", "-o", "all")
-	args = append(args, d.Name)
-
-	out, err := d.z.doOutput(args...)
-	if err != nil {
+	datasets, err := d.z.list(d.Name, ListOptions{Depth: depth})
+	if err != nil || len(datasets) == 0 {
 		return nil, err
 	}
-
-	if len(out) == 0 {
-		return nil, nil
-	}
-
-	var datasets []*Dataset
-	name := ""
-	var ds *Dataset
-	for _, line := range out[1:] {
-		if name != line[0] {
-			name = line[0]
-			ds = &Dataset{z: d.z, Name: name, props: make(map[string]string)}
-			datasets = append(datasets, ds)
-		}
-		if err := ds.parseProps([][]string{out[0], line}); err != nil {
-			return nil, err
-		}
-	}
 	return datasets[1:], nil
 }
 
@@ -558,3 +1735,71 @@ func (d *Dataset) Diff(snapshot string) ([]*InodeChange, error) {
 	}
 	return inodeChanges, nil
 }
+
+// DiffAgainst returns the changes between a snapshot and another ZFS dataset, which may be a filesystem or a later snapshot.
+// ZFS only compares a snapshot with a dataset of the same filesystem, or of a clone of that filesystem,
+// e.g. to validate a migration to a clone: an error is returned otherwise.
+func (z *zfs) DiffAgainst(snapshot, other string) ([]*InodeChange, error) {
+	i := strings.Index(snapshot, "@")
+	if i < 0 {
+		return nil, fmt.Errorf("%s is not a snapshot", snapshot)
+	}
+	fs, otherFs := snapshot[:i], other
+	if j := strings.Index(other, "@"); j >= 0 {
+		otherFs = other[:j]
+	}
+	if otherFs != fs {
+		out, err := z.doOutput("get", "-Hp", "-o", "value", "origin", otherFs)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || !strings.HasPrefix(out[0][0], fs+"@") {
+			return nil, fmt.Errorf("cannot compare %s with %s: %s is not %s or one of its clones", snapshot, other, otherFs, fs)
+		}
+	}
+	out, err := z.doOutput("diff", "-FH", snapshot, other)
+	if err != nil {
+		return nil, err
+	}
+	return parseInodeChanges(out)
+}
+
+// DiffSummary counts the inode changes reported by Diff, by ChangeType.
+// zfs diff does not report sizes: the written@snapshot property holds the space written since a snapshot.
+type DiffSummary struct {
+	Created  int
+	Modified int
+	Removed  int
+	Renamed  int
+}
+
+// Total returns the total number of changes.
+func (s DiffSummary) Total() int {
+	return s.Created + s.Modified + s.Removed + s.Renamed
+}
+
+// DiffSummary returns the number of changes of each type between a snapshot and the given ZFS dataset, as reported by Diff.
+func (d *Dataset) DiffSummary(snapshot string) (DiffSummary, error) {
+	changes, err := d.Diff(snapshot)
+	if err != nil {
+		return DiffSummary{}, err
+	}
+	return summarizeDiff(changes), nil
+}
+
+func summarizeDiff(changes []*InodeChange) DiffSummary {
+	var s DiffSummary
+	for _, c := range changes {
+		switch c.Change {
+		case Created:
+			s.Created++
+		case Modified:
+			s.Modified++
+		case Removed:
+			s.Removed++
+		case Renamed:
+			s.Renamed++
+		}
+	}
+	return s
+}
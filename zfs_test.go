@@ -1,11 +1,14 @@
 package zfs_test
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"testing"
+	"time"
 
 	"go.linka.cloud/go-zfs/v3"
 )
@@ -22,9 +25,112 @@ func TestDatasets(t *testing.T) {
 	equals(t, "", ds.Origin)
 	if runtime.GOOS != "solaris" {
 		assert(t, ds.Logicalused != 0, "Logicalused is not greater than 0")
+		assert(t, ds.LogicalReferenced != 0, "LogicalReferenced is not greater than 0")
+		assert(t, ds.CompressRatio >= 1, "CompressRatio is lower than 1")
+		assert(t, ds.RefCompressRatio >= 1, "RefCompressRatio is lower than 1")
 	}
 }
 
+func TestExists(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	exists, err := zfs.Exists("test")
+	ok(t, err)
+	assert(t, exists, "test pool root dataset should exist")
+
+	exists, err = zfs.Exists("test/missing")
+	ok(t, err)
+	assert(t, !exists, "test/missing should not exist")
+
+	_, err = zfs.GetDataset("test/missing")
+	assert(t, errors.Is(err, zfs.ErrDatasetNotFound), "unexpected error: %v", err)
+}
+
+func TestMountState(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/mounted", nil)
+	ok(t, err)
+	off, err := zfs.CreateFilesystem("test/notmounted", map[string]string{"canmount": "off"})
+	ok(t, err)
+
+	mounted, err := f.IsMounted()
+	ok(t, err)
+	assert(t, mounted, "test/mounted should be mounted")
+	mountpoint, err := f.ActualMountpoint()
+	ok(t, err)
+	equals(t, f.Mountpoint, mountpoint)
+
+	mounted, err = off.IsMounted()
+	ok(t, err)
+	assert(t, !mounted, "test/notmounted should not be mounted")
+	mountpoint, err = off.ActualMountpoint()
+	ok(t, err)
+	equals(t, "", mountpoint)
+
+	ok(t, off.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestDatasetSpaceAccounting(t *testing.T) {
+	if runtime.GOOS == "solaris" {
+		t.Skip("space accounting fields are not parsed on solaris")
+	}
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/space", map[string]string{
+		"refquota":       strconv.Itoa(int(pow2(26))),
+		"refreservation": strconv.Itoa(int(pow2(25))),
+	})
+	ok(t, err)
+
+	equals(t, uint64(pow2(26)), f.Refquota)
+	equals(t, uint64(pow2(25)), f.Refreservation)
+	assert(t, f.Usedbyrefreservation != 0, "Usedbyrefreservation is not greater than 0")
+
+	root, err := zfs.GetDataset("test")
+	ok(t, err)
+	assert(t, root.Usedbychildren != 0, "Usedbychildren is not greater than 0")
+	equals(t, uint64(0), root.Usedbysnapshots)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestDatasetEncryption(t *testing.T) {
+	if runtime.GOOS == "solaris" {
+		t.Skip("encryption fields are not parsed on solaris")
+	}
+	defer setupZPool(t).cleanUp()
+
+	root, err := zfs.GetDataset("test")
+	ok(t, err)
+	equals(t, "off", root.Encryption)
+	equals(t, "", root.KeyStatus)
+	equals(t, "", root.EncryptionRoot)
+}
+
+func TestDatasetRefresh(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/refresh", nil)
+	ok(t, err)
+	equals(t, "off", f.Compression)
+
+	other, err := zfs.GetDataset("test/refresh")
+	ok(t, err)
+	ok(t, other.SetProperty("compression", "lz4"))
+
+	ok(t, f.Refresh())
+	equals(t, "lz4", f.Compression)
+
+	prop, err := f.GetProperty("compression")
+	ok(t, err)
+	equals(t, "lz4", prop)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+	nok(t, f.Refresh())
+}
+
 func TestDatasetGetProperty(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -47,6 +153,92 @@ func TestDatasetGetProperty(t *testing.T) {
 	}
 }
 
+func TestGetPropertyMany(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/property-many", map[string]string{"compression": "lz4"})
+	ok(t, err)
+
+	props, err := zfs.GetPropertyMany([]string{"test", "test/property-many", "test/missing"}, "compression")
+	ok(t, err)
+	equals(t, map[string]string{"test": "off", "test/property-many": "lz4"}, props)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestDatasetGetPropertyRecursive(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/recursive", map[string]string{"compression": "lz4"})
+	ok(t, err)
+	c, err := zfs.CreateFilesystem("test/recursive/child", nil)
+	ok(t, err)
+
+	root, err := zfs.GetDataset("test")
+	ok(t, err)
+
+	props, err := root.GetPropertyRecursive("compression")
+	ok(t, err)
+	equals(t, map[string]string{"test": "off", "test/recursive": "lz4", "test/recursive/child": "lz4"}, props)
+
+	props, err = root.GetPropertyRecursive("compression", 1)
+	ok(t, err)
+	equals(t, map[string]string{"test": "off", "test/recursive": "lz4"}, props)
+
+	ok(t, c.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestDatasetSetPropertyRecursive(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/recursive", nil)
+	ok(t, err)
+	c, err := zfs.CreateFilesystem("test/recursive/child", nil)
+	ok(t, err)
+	s, err := c.Snapshot("snap", false)
+	ok(t, err)
+	b, err := s.Bookmark("mark")
+	ok(t, err)
+
+	ok(t, f.SetPropertyRecursive("atime", "off"))
+
+	props, err := f.GetPropertyRecursive("atime")
+	ok(t, err)
+	equals(t, "off", props["test/recursive"])
+	equals(t, "off", props["test/recursive/child"])
+
+	ok(t, b.Destroy(zfs.DestroyDefault))
+	ok(t, s.Destroy(zfs.DestroyDefault))
+	ok(t, c.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestDatasetUserProperty(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	ds, err := zfs.GetDataset("test")
+	ok(t, err)
+
+	prop, err := ds.GetUserProperty("com.example", "lastbackup")
+	ok(t, err)
+	equals(t, "-", prop)
+
+	ok(t, ds.SetUserProperty("com.example", "lastbackup", "1648598400"))
+
+	prop, err = ds.GetUserProperty("com.example", "lastbackup")
+	ok(t, err)
+	equals(t, "1648598400", prop)
+
+	props, err := ds.GetAllProperties()
+	ok(t, err)
+	equals(t, "1648598400", props["com.example:lastbackup"])
+
+	nok(t, ds.SetUserProperty("com.example", "LastBackup", "1"))
+	nok(t, ds.SetUserProperty("com:example", "lastbackup", "1"))
+	nok(t, ds.SetUserProperty("", "lastbackup", "1"))
+}
+
 func TestSnapshots(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -58,6 +250,26 @@ func TestSnapshots(t *testing.T) {
 	}
 }
 
+func TestListSnapshotsBrief(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	snapshots, err := zfs.ListSnapshotsBrief("test/snapshot-test")
+	ok(t, err)
+	equals(t, 1, len(snapshots))
+	equals(t, s.Name, snapshots[0].Name)
+	equals(t, s.Referenced, snapshots[0].Referenced)
+	assert(t, time.Since(snapshots[0].Creation) < time.Hour, "unexpected creation time: %s", snapshots[0].Creation)
+
+	ok(t, s.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
 func TestFilesystems(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -96,6 +308,54 @@ func TestCreateFilesystemWithProperties(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestCreateFilesystemWithOptions(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	_, err := zfs.CreateFilesystem("test/a/b/c", nil)
+	nok(t, err)
+
+	f, err := zfs.CreateFilesystem("test/a/b/c", nil, zfs.CreateFilesystemOptions{CreateParents: true})
+	ok(t, err)
+	assert(t, f.Mounted, "filesystem should be mounted")
+
+	p, err := zfs.GetDataset("test/a/b")
+	ok(t, err)
+	equals(t, zfs.DatasetFilesystem, p.Type)
+
+	u, err := zfs.CreateFilesystem("test/a/unmounted", nil, zfs.CreateFilesystemOptions{NoMount: true})
+	ok(t, err)
+	assert(t, !u.Mounted, "filesystem should not be mounted")
+
+	a, err := zfs.GetDataset("test/a")
+	ok(t, err)
+	ok(t, a.Destroy(zfs.DestroyRecursive))
+}
+
+func TestEnsureFilesystem(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.EnsureFilesystem("test/ensure/fs", map[string]string{"compression": "lz4"}, false)
+	ok(t, err)
+	equals(t, "lz4", f.Compression)
+
+	f, err = zfs.EnsureFilesystem("test/ensure/fs", map[string]string{"compression": "off"}, false)
+	ok(t, err)
+	equals(t, "lz4", f.Compression)
+
+	f, err = zfs.EnsureFilesystem("test/ensure/fs", map[string]string{"compression": "off"}, true)
+	ok(t, err)
+	equals(t, "off", f.Compression)
+
+	s, err := f.Snapshot("s", false)
+	ok(t, err)
+	_, err = zfs.EnsureFilesystem(s.Name, nil, false)
+	nok(t, err)
+
+	p, err := zfs.GetDataset("test/ensure")
+	ok(t, err)
+	ok(t, p.Destroy(zfs.DestroyRecursive))
+}
+
 func TestVolumes(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -116,6 +376,43 @@ func TestVolumes(t *testing.T) {
 	ok(t, v.Destroy(zfs.DestroyDefault))
 }
 
+func TestListVolumesUnder(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	_, err := zfs.CreateFilesystem("test/vms/nested", nil, zfs.CreateFilesystemOptions{CreateParents: true})
+	ok(t, err)
+	_, err = zfs.CreateVolume("test/vms/vm1", uint64(pow2(23)), nil)
+	ok(t, err)
+	_, err = zfs.CreateVolume("test/vms/nested/vm2", uint64(pow2(23)), nil)
+	ok(t, err)
+	_, err = zfs.CreateVolume("test/other", uint64(pow2(23)), nil)
+	ok(t, err)
+
+	// volumes are sometimes "busy" if you try to manipulate them right away
+	sleep(1)
+
+	volumes, err := zfs.ListVolumes("test/vms", false)
+	ok(t, err)
+	equals(t, 1, len(volumes))
+	equals(t, "test/vms/vm1", volumes[0].Name)
+
+	volumes, err = zfs.ListVolumes("test/vms", true)
+	ok(t, err)
+	equals(t, 2, len(volumes))
+
+	filesystems, err := zfs.ListFilesystems("test/vms", true)
+	ok(t, err)
+	equals(t, 1, len(filesystems))
+	equals(t, "test/vms/nested", filesystems[0].Name)
+
+	vms, err := zfs.GetDataset("test/vms")
+	ok(t, err)
+	ok(t, vms.Destroy(zfs.DestroyRecursive))
+	other, err := zfs.GetDataset("test/other")
+	ok(t, err)
+	ok(t, other.Destroy(zfs.DestroyDefault))
+}
+
 func TestSnapshot(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -136,11 +433,145 @@ func TestSnapshot(t *testing.T) {
 
 	equals(t, "test/snapshot-test@test", s.Name)
 
+	if runtime.GOOS != "solaris" {
+		assert(t, s.CreateTxg >= f.CreateTxg, "snapshot should be created after its filesystem")
+		assert(t, s.ObjsetID != 0, "snapshot should have an objset id")
+	}
+
+	assert(t, !s.IsSet("available"), "snapshots should not have available space")
+	assert(t, s.IsSet("used"), "snapshots should have used space")
+	assert(t, f.IsSet("available"), "filesystems should have available space")
+	assert(t, !f.IsSet("volsize"), "filesystems should not have a volume size")
+
 	ok(t, s.Destroy(zfs.DestroyDefault))
 
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestSnapshotWithProperties(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-props-test", nil)
+	ok(t, err)
+
+	s, err := f.SnapshotWithProperties("test", false, map[string]string{"com.example:reason": "backup"})
+	ok(t, err)
+
+	reason, err := s.GetProperty("com.example:reason")
+	ok(t, err)
+	equals(t, "backup", reason)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestDestroySnapshotRange(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-range-test", nil)
+	ok(t, err)
+
+	for _, name := range []string{"s1", "s2", "s3", "s4"} {
+		_, err = f.Snapshot(name, false)
+		ok(t, err)
+	}
+
+	nok(t, f.DestroySnapshotRange("s3", "s2", zfs.DestroyDefault))
+	ok(t, f.DestroySnapshotRange("s2", "s3", zfs.DestroyDefault))
+
+	snapshots, err := f.Snapshots()
+	ok(t, err)
+	equals(t, 2, len(snapshots))
+	equals(t, "test/snapshot-range-test@s1", snapshots[0].Name)
+	equals(t, "test/snapshot-range-test@s4", snapshots[1].Name)
+
+	nok(t, snapshots[0].DestroySnapshotRange("s1", "s4", zfs.DestroyDefault))
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestDestroySnapshots(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshots-destroy-test", nil)
+	ok(t, err)
+
+	for _, name := range []string{"s1", "s2", "s3"} {
+		_, err = f.Snapshot(name, false)
+		ok(t, err)
+	}
+
+	nok(t, f.DestroySnapshots([]string{"s1", ""}, zfs.DestroyDefault))
+	nok(t, f.DestroySnapshots([]string{"test/snapshots-destroy-test@s1"}, zfs.DestroyDefault))
+	ok(t, f.DestroySnapshots([]string{"s1", "s3"}, zfs.DestroyDefault))
+
+	snapshots, err := f.Snapshots()
+	ok(t, err)
+	equals(t, 1, len(snapshots))
+	equals(t, "test/snapshots-destroy-test@s2", snapshots[0].Name)
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestRenameWithOptions(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/rename-test", nil)
+	ok(t, err)
+	assert(t, f.Mounted, "filesystem should be mounted")
+
+	busy, err := os.Open(f.Mountpoint)
+	ok(t, err)
+	defer busy.Close()
+
+	f, err = f.RenameWithOptions("test/renamed/rename-test", zfs.RenameOptions{CreateParent: true, Force: true})
+	ok(t, err)
+	equals(t, "test/renamed/rename-test", f.Name)
+
+	f, err = f.RenameWithOptions("test/renamed/unmounted", zfs.RenameOptions{NoRemount: true})
+	ok(t, err)
+	equals(t, "test/renamed/unmounted", f.Name)
+
+	ok(t, f.Destroy(zfs.DestroyForceUmount))
+	p, err := zfs.GetDataset("test/renamed")
+	ok(t, err)
+	ok(t, p.Destroy(zfs.DestroyDefault))
+}
+
+func TestRenameSnapshot(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	c, err := zfs.CreateFilesystem("test/snapshot-test/child", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", true)
+	ok(t, err)
+
+	_, err = s.RenameSnapshot("test/other@renamed", false)
+	nok(t, err)
+
+	_, err = f.RenameSnapshot("renamed", false)
+	nok(t, err)
+
+	s, err = s.RenameSnapshot("renamed", true)
+	ok(t, err)
+	equals(t, "test/snapshot-test@renamed", s.Name)
+
+	cs, err := zfs.GetDataset("test/snapshot-test/child@renamed")
+	ok(t, err)
+
+	s, err = s.RenameSnapshot("test/snapshot-test@final", false)
+	ok(t, err)
+	equals(t, "test/snapshot-test@final", s.Name)
+
+	ok(t, cs.Destroy(zfs.DestroyDefault))
+	ok(t, s.Destroy(zfs.DestroyDefault))
+	ok(t, c.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
 func TestClone(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -160,11 +591,31 @@ func TestClone(t *testing.T) {
 	equals(t, zfs.DatasetSnapshot, s.Type)
 	equals(t, "test/snapshot-test@test", s.Name)
 
+	clones, err := s.Clones()
+	ok(t, err)
+	equals(t, 0, len(clones))
+
 	c, err := s.Clone("test/clone-test", nil)
 	ok(t, err)
 
 	equals(t, zfs.DatasetFilesystem, c.Type)
 
+	o, err := c.OriginDataset()
+	ok(t, err)
+	equals(t, s.Name, o.Name)
+
+	o, err = f.OriginDataset()
+	ok(t, err)
+	assert(t, o == nil, "filesystem should not have an origin")
+
+	clones, err = s.Clones()
+	ok(t, err)
+	equals(t, 1, len(clones))
+	equals(t, "test/clone-test", clones[0].Name)
+
+	_, err = f.Clones()
+	nok(t, err)
+
 	ok(t, c.Destroy(zfs.DestroyDefault))
 
 	ok(t, s.Destroy(zfs.DestroyDefault))
@@ -172,6 +623,78 @@ func TestClone(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestCloneTemp(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/clone-temp-test", nil)
+	ok(t, err)
+
+	d, err := os.MkdirTemp("/tmp/", "zfs-clone-*")
+	ok(t, err)
+	defer os.RemoveAll(d)
+
+	c, cleanup, err := f.CloneTemp("test/clone-temp-work", d)
+	ok(t, err)
+	equals(t, d, c.Mountpoint)
+	assert(t, c.Mounted, "clone should be mounted")
+
+	ok(t, cleanup())
+
+	datasets, err := zfs.Datasets("test/clone-temp-test")
+	ok(t, err)
+	equals(t, 1, len(datasets))
+	_, err = zfs.GetDataset("test/clone-temp-work")
+	nok(t, err)
+
+	// the clone unmounted during the work is only destroyed
+	c, cleanup, err = f.CloneTemp("test/clone-temp-work", d)
+	ok(t, err)
+	_, err = c.Unmount(false)
+	ok(t, err)
+	ok(t, cleanup())
+	_, err = zfs.GetDataset("test/clone-temp-work")
+	nok(t, err)
+
+	v, err := zfs.CreateVolume("test/clone-temp-volume", uint64(pow2(23)), nil)
+	ok(t, err)
+	c, cleanup, err = v.CloneTemp("test/clone-temp-volume-work", d)
+	ok(t, err)
+	equals(t, zfs.DatasetVolume, c.Type)
+	ok(t, cleanup())
+	_, err = zfs.GetDataset("test/clone-temp-volume-work")
+	nok(t, err)
+
+	ok(t, v.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestCloneUnmounted(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/snapshot-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	c, err := s.Clone("test/clone-test", nil)
+	ok(t, err)
+	assert(t, c.Mounted, "clone should be mounted")
+
+	u, err := s.CloneUnmounted("test/clone-unmounted", nil)
+	ok(t, err)
+	assert(t, !u.Mounted, "clone should not be mounted")
+
+	canmount, err := u.GetProperty("canmount")
+	ok(t, err)
+	equals(t, "noauto", canmount)
+
+	ok(t, u.Destroy(zfs.DestroyDefault))
+	ok(t, c.Destroy(zfs.DestroyDefault))
+	ok(t, s.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
 func TestSendSnapshot(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -202,6 +725,115 @@ func TestSendSnapshot(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestSendToFile(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/send-test", nil)
+	ok(t, err)
+
+	s, err := f.Snapshot("test", false)
+	ok(t, err)
+
+	d, err := os.MkdirTemp("/tmp/", "zfs-send-*")
+	ok(t, err)
+	defer os.RemoveAll(d)
+
+	path := filepath.Join(d, "snap.zfs")
+	nok(t, f.SendToFile(path, zfs.SendOptions{}))
+	_, err = os.Stat(path)
+	assert(t, os.IsNotExist(err), "no file should be left behind")
+
+	ok(t, s.SendToFile(path, zfs.SendOptions{Compressed: true}))
+
+	res, err := zfs.ReceiveFromFile(path, "test/receive-test", zfs.ReceiveOptions{
+		NoMount:    true,
+		Properties: map[string]string{"canmount": "off"},
+	})
+	ok(t, err)
+	r := res.Dataset
+	equals(t, "test/receive-test", r.Name)
+	assert(t, !r.Mounted, "received filesystem should not be mounted")
+
+	ok(t, r.Destroy(zfs.DestroyRecursive))
+	ok(t, s.Destroy(zfs.DestroyDefault))
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestVerifyReplication(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/replication-src", nil)
+	ok(t, err)
+
+	s1, err := f.Snapshot("s1", false)
+	ok(t, err)
+	s2, err := f.Snapshot("s2", false)
+	ok(t, err)
+
+	d, err := os.MkdirTemp("/tmp/", "zfs-send-*")
+	ok(t, err)
+	defer os.RemoveAll(d)
+
+	path := filepath.Join(d, "s1.zfs")
+	ok(t, s1.SendToFile(path, zfs.SendOptions{}))
+	_, err = zfs.ReceiveFromFile(path, "test/replication-dst@s1", zfs.ReceiveOptions{})
+	ok(t, err)
+
+	r1, err := zfs.GetDataset("test/replication-dst@s1")
+	ok(t, err)
+
+	ok(t, zfs.VerifyReplication(s1, r1))
+	equals(t, s1.GUID, r1.GUID)
+
+	from, to, err := s2.SendGUIDs(s1)
+	ok(t, err)
+	equals(t, s1.GUID, from)
+	equals(t, s2.GUID, to)
+
+	err = zfs.VerifyReplication(s2, r1)
+	var mismatch *zfs.ReplicationMismatchError
+	assert(t, errors.As(err, &mismatch), "expected a ReplicationMismatchError, got: %v", err)
+	equals(t, s2.Name, mismatch.Source)
+
+	nok(t, zfs.VerifyReplication(f, r1))
+
+	r, err := zfs.GetDataset("test/replication-dst")
+	ok(t, err)
+	ok(t, r.Destroy(zfs.DestroyRecursive))
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
+func TestBookmarkIncrementalSend(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	f, err := zfs.CreateFilesystem("test/bookmark-test", nil)
+	ok(t, err)
+
+	s1, err := f.Snapshot("s1", false)
+	ok(t, err)
+
+	b, err := s1.Bookmark("b1")
+	ok(t, err)
+	equals(t, "test/bookmark-test#b1", b.Name)
+	equals(t, zfs.DatasetBookmark, b.Type)
+
+	_, err = f.Bookmark("b2")
+	nok(t, err)
+
+	bookmarks, err := zfs.Bookmarks("test/bookmark-test")
+	ok(t, err)
+	equals(t, 1, len(bookmarks))
+
+	s2, err := f.Snapshot("s2", false)
+	ok(t, err)
+	ok(t, s1.Destroy(zfs.DestroyDefault))
+
+	ok(t, s2.IncrementalSendFrom(b, io.Discard))
+	nok(t, s2.IncrementalSendFrom(f, io.Discard))
+
+	ok(t, f.Destroy(zfs.DestroyRecursive))
+}
+
 func TestChildren(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -224,6 +856,43 @@ func TestChildren(t *testing.T) {
 	ok(t, f.Destroy(zfs.DestroyDefault))
 }
 
+func TestList(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	_, err := zfs.CreateFilesystem("test/list/child", nil, zfs.CreateFilesystemOptions{CreateParents: true})
+	ok(t, err)
+
+	v, err := zfs.CreateVolume("test/list/volume", uint64(pow2(23)), nil)
+	ok(t, err)
+
+	datasets, err := zfs.List("test", zfs.ListOptions{Depth: 1})
+	ok(t, err)
+	equals(t, 2, len(datasets))
+	equals(t, "test", datasets[0].Name)
+	equals(t, "test/list", datasets[1].Name)
+
+	datasets, err = zfs.List("test/list", zfs.ListOptions{Types: []string{zfs.DatasetVolume}})
+	ok(t, err)
+	equals(t, 1, len(datasets))
+	equals(t, v.Name, datasets[0].Name)
+
+	datasets, err = zfs.List("test/list", zfs.ListOptions{Types: []string{zfs.DatasetFilesystem, zfs.DatasetVolume}})
+	ok(t, err)
+	equals(t, 3, len(datasets))
+
+	datasets, err = zfs.List("test/list", zfs.ListOptions{Types: []string{zfs.DatasetFilesystem, zfs.DatasetVolume}, SortBy: "used", SortDescending: true})
+	ok(t, err)
+	equals(t, 3, len(datasets))
+	for i := 1; i < len(datasets); i++ {
+		assert(t, datasets[i-1].Used >= datasets[i].Used, "datasets are not sorted by used space")
+	}
+
+	sleep(1)
+	l, err := zfs.GetDataset("test/list")
+	ok(t, err)
+	ok(t, l.Destroy(zfs.DestroyRecursive))
+}
+
 func TestListZpool(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -238,6 +907,111 @@ func TestListZpool(t *testing.T) {
 	t.Fatal("Failed to find test pool")
 }
 
+func TestZpoolRefresh(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+
+	f, err := zfs.CreateFilesystem("test/refresh", nil)
+	ok(t, err)
+
+	ok(t, pool.Refresh())
+	equals(t, "test", pool.Name)
+	equals(t, zfs.ZpoolOnline, pool.Health)
+
+	fresh, err := zfs.GetZpool("test")
+	ok(t, err)
+	equals(t, fresh.Allocated, pool.Allocated)
+	equals(t, fresh.Free, pool.Free)
+
+	ok(t, f.Destroy(zfs.DestroyDefault))
+}
+
+func TestZpoolReguid(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+	guid := pool.GUID
+
+	ok(t, pool.Reguid())
+	assert(t, pool.GUID != 0 && pool.GUID != guid, "the guid should have changed from %d, got %d", guid, pool.GUID)
+}
+
+func TestZpoolCapacityAndFeatures(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+
+	c := pool.Capacity()
+	assert(t, c > 0 && c < 100, "unexpected capacity: %f", c)
+
+	features, err := pool.Features()
+	ok(t, err)
+	assert(t, len(features) > 0, "no feature flags found")
+	equals(t, zfs.FeatureActive, features["lz4_compress"])
+}
+
+func TestZpoolVdevStats(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	pool, err := zfs.GetZpool("test")
+	ok(t, err)
+
+	stats, err := pool.VdevStats()
+	ok(t, err)
+	equals(t, 3, len(stats))
+	for _, s := range stats {
+		equals(t, "", s.Parent)
+		equals(t, zfs.ZpoolOnline, s.Health)
+		assert(t, s.Size > 0, "vdev %s has no size", s.Name)
+		assert(t, s.Allocated+s.Free <= s.Size, "vdev %s allocation exceeds its size", s.Name)
+	}
+}
+
+func TestCreateZpoolWithVdevs(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	d, err := os.MkdirTemp("/tmp/", "zfs-test-*")
+	ok(t, err)
+	defer os.RemoveAll(d)
+
+	devices := make([]string, 2)
+	for i := range devices {
+		f, err := os.CreateTemp(d, "mirror")
+		ok(t, err)
+		ok(t, f.Truncate(pow2(27)))
+		f.Close()
+		devices[i] = f.Name()
+	}
+
+	_, err = zfs.CreateZpoolWithVdevs("test-vdevs", nil, []zfs.VdevSpec{{Type: zfs.VdevMirror, Devices: devices[:1]}}, zfs.CreateZpoolOptions{})
+	nok(t, err)
+
+	pool, err := zfs.CreateZpoolWithVdevs("test-vdevs", nil, []zfs.VdevSpec{{Type: zfs.VdevMirror, Devices: devices}}, zfs.CreateZpoolOptions{Mountpoint: "none"})
+	ok(t, err)
+	equals(t, "test-vdevs", pool.Name)
+
+	stats, err := pool.VdevStats()
+	ok(t, err)
+	equals(t, 3, len(stats))
+	equals(t, "", stats[0].Parent)
+	for _, s := range stats[1:] {
+		equals(t, stats[0].Name, s.Parent)
+	}
+
+	cache, err := os.CreateTemp(d, "cache")
+	ok(t, err)
+	ok(t, cache.Truncate(pow2(27)))
+	cache.Close()
+
+	ok(t, pool.Add([]zfs.VdevSpec{{Class: zfs.VdevClassCache, Devices: []string{cache.Name()}}}, false))
+
+	ok(t, pool.Destroy())
+}
+
 func TestRollback(t *testing.T) {
 	defer setupZPool(t).cleanUp()
 
@@ -305,6 +1079,11 @@ func TestDiff(t *testing.T) {
 	ok(t, err)
 	equals(t, 4, len(inodeChanges))
 
+	summary, err := fs.DiffSummary(snapshot.Name)
+	ok(t, err)
+	equals(t, zfs.DiffSummary{Created: 1, Modified: 2, Renamed: 1}, summary)
+	equals(t, 4, summary.Total())
+
 	unicodePath := "/test/origin/i\x040\x1c2\x135\x144\x040unicode"
 	wants := map[string]*zfs.InodeChange{
 		"/test/origin/linked": {
@@ -351,3 +1130,61 @@ func TestDiff(t *testing.T) {
 	ok(t, snapshot.Destroy(zfs.DestroyForceUmount))
 	ok(t, fs.Destroy(zfs.DestroyForceUmount))
 }
+
+func TestZpoolSplit(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	d, err := os.MkdirTemp("/tmp/", "zfs-test-*")
+	ok(t, err)
+	defer os.RemoveAll(d)
+
+	devices := make([]string, 2)
+	for i := range devices {
+		f, err := os.CreateTemp(d, "mirror")
+		ok(t, err)
+		ok(t, f.Truncate(pow2(27)))
+		f.Close()
+		devices[i] = f.Name()
+	}
+
+	pool, err := zfs.CreateZpoolWithVdevs("test-split", nil, []zfs.VdevSpec{{Type: zfs.VdevMirror, Devices: devices}}, zfs.CreateZpoolOptions{Mountpoint: "none"})
+	ok(t, err)
+
+	split, err := pool.Split("test-split-backup", zfs.SplitOptions{
+		Devices:    devices[1:],
+		Properties: map[string]string{"comment": "backup"},
+		Altroot:    d,
+	})
+	ok(t, err)
+	equals(t, "test-split-backup", split.Name)
+	equals(t, zfs.ZpoolOnline, split.Health)
+
+	ok(t, split.Destroy())
+	ok(t, pool.Destroy())
+}
+
+func TestZpoolRename(t *testing.T) {
+	defer setupZPool(t).cleanUp()
+
+	d, err := os.MkdirTemp("/tmp/", "zfs-test-*")
+	ok(t, err)
+	defer os.RemoveAll(d)
+
+	f, err := os.CreateTemp(d, "rename")
+	ok(t, err)
+	ok(t, f.Truncate(pow2(27)))
+	f.Close()
+
+	pool, err := zfs.CreateZpool("test-rename", nil, f.Name())
+	ok(t, err)
+
+	renamed, err := pool.Rename("test-renamed", d)
+	ok(t, err)
+	equals(t, "test-renamed", renamed.Name)
+	equals(t, zfs.ZpoolOnline, renamed.Health)
+
+	_, err = zfs.GetZpool("test-rename")
+	assert(t, err != nil, "test-rename should not exist anymore")
+
+	ok(t, renamed.Destroy())
+}
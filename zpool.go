@@ -1,16 +1,25 @@
 package zfs
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
 // ZFS zpool states, which can indicate if a pool is online, offline, degraded, etc.
 //
 // More information regarding zpool states can be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zpoolconcepts.7.html#Device_Failure_and_Recovery
 const (
-	ZpoolOnline   = "ONLINE"
-	ZpoolDegraded = "DEGRADED"
-	ZpoolFaulted  = "FAULTED"
-	ZpoolOffline  = "OFFLINE"
-	ZpoolUnavail  = "UNAVAIL"
-	ZpoolRemoved  = "REMOVED"
+	ZpoolOnline    = "ONLINE"
+	ZpoolDegraded  = "DEGRADED"
+	ZpoolFaulted   = "FAULTED"
+	ZpoolOffline   = "OFFLINE"
+	ZpoolUnavail   = "UNAVAIL"
+	ZpoolRemoved   = "REMOVED"
+	ZpoolSuspended = "SUSPENDED"
 )
 
 // Zpool is a ZFS zpool.
@@ -27,6 +36,20 @@ type Zpool struct {
 	Freeing       uint64
 	Leaked        uint64
 	DedupRatio    float64
+	// GUID uniquely identifies the zpool, e.g. to tell which pool a replicated snapshot comes from.
+	GUID uint64
+
+	// Ashift is the base 2 logarithm of the sector size of the zpool, or 0 when it is detected automatically.
+	Ashift      uint64
+	Autoexpand  bool
+	Autoreplace bool
+	Autotrim    bool
+	// Cachefile is the configuration cache file of the zpool, empty for the default one, or "none".
+	Cachefile string
+	// Bootfs is the default bootable dataset of the zpool, if any.
+	Bootfs string
+
+	props map[string]string
 }
 
 // zpool is a helper function to wrap typical calls to zpool and ignores stdout.
@@ -59,6 +82,171 @@ func (z *zfs) GetZpool(name string) (*Zpool, error) {
 	return pool, nil
 }
 
+// Refresh reloads the receiving zpool from ZFS, updating all its fields in place.
+func (z *Zpool) Refresh() error {
+	pool, err := z.z.GetZpool(z.Name)
+	if err != nil {
+		return err
+	}
+	*z = *pool
+	return nil
+}
+
+// IsSet reports whether the zpool property has a value, as of its last retrieval.
+// Properties which ZFS cannot report, such as the fragmentation of some pools, are reported as "-":
+// they are not set, and their parsed field is left to its zero value.
+func (z *Zpool) IsSet(property string) bool {
+	v, ok := z.props[strings.ToLower(property)]
+	return ok && v != "-" && v != ""
+}
+
+// Capacity returns the percentage of the zpool size which is allocated.
+func (z *Zpool) Capacity() float64 {
+	if z.Size == 0 {
+		return 0
+	}
+	return float64(z.Allocated) / float64(z.Size) * 100
+}
+
+// GetProperty returns the current value of a zpool property, as of its last retrieval for the properties parsed into fields,
+// or as read from ZFS for the others.
+//
+// A full list of available zpool properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zpoolprops.7.html.
+func (z *Zpool) GetProperty(key string) (string, error) {
+	if v, ok := z.props[strings.ToLower(key)]; ok {
+		return v, nil
+	}
+	out, err := z.z.zpoolOutput("get", "-Hp", key, z.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(out) == 0 || len(out[0]) < 3 {
+		return "", fmt.Errorf("unexpected output getting property %s of %s", key, z.Name)
+	}
+	return out[0][2], nil
+}
+
+// GetAllProperties returns the current values of all the zpool properties, including the feature flags, keyed by property name.
+func (z *Zpool) GetAllProperties() (map[string]string, error) {
+	out, err := z.z.zpoolOutput("get", "-Hp", "all", z.Name)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string, len(out))
+	for _, line := range out {
+		if len(line) < 3 {
+			continue
+		}
+		props[line[1]] = line[2]
+	}
+	return props, nil
+}
+
+// SetProperty sets a zpool property, updating the matching field of the receiving zpool.
+//
+// A full list of available zpool properties may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zpoolprops.7.html.
+func (z *Zpool) SetProperty(key, val string) error {
+	if err := z.z.zpool("set", key+"="+val, z.Name); err != nil {
+		return err
+	}
+	return z.parseLine([]string{z.Name, strings.ToLower(key), val})
+}
+
+// Feature flag states, as returned by FeatureFlags.
+const (
+	// FeatureDisabled is the state of a feature which must be enabled before use, e.g. with zpool upgrade.
+	FeatureDisabled = "disabled"
+	// FeatureEnabled is the state of a feature which may be used, but is not used by the on-disk format yet.
+	FeatureEnabled = "enabled"
+	// FeatureActive is the state of a feature which is used by the on-disk format:
+	// the zpool cannot be imported by a system which does not support it.
+	FeatureActive = "active"
+)
+
+// Features returns the state (disabled, enabled or active) of each feature flag of the zpool, keyed by feature name,
+// i.e. the feature@ properties without their prefix, see the Feature constants.
+//
+// More information about feature flags may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zpool-features.7.html
+func (z *Zpool) Features() (map[string]string, error) {
+	out, err := z.z.zpoolOutput("get", "-Hp", "-o", "property,value", "all", z.Name)
+	if err != nil {
+		return nil, err
+	}
+	features := make(map[string]string)
+	for _, line := range out {
+		if len(line) < 2 || !strings.HasPrefix(line[0], "feature@") {
+			continue
+		}
+		features[strings.TrimPrefix(line[0], "feature@")] = line[1]
+	}
+	return features, nil
+}
+
+// FeatureFlags returns the state (disabled, enabled or active) of each feature flag of the zpool, keyed by feature name,
+// e.g. to decide whether to upgrade the zpool or whether it can receive a stream. It is the same as Features.
+func (z *Zpool) FeatureFlags() (map[string]string, error) {
+	return z.Features()
+}
+
+// VdevStat is the space usage of a vdev of a zpool, as reported by `zpool list -v`.
+// Only the top-level vdevs report their space usage, the devices they are made of only report their physical size.
+type VdevStat struct {
+	// Name is the name of the vdev, e.g. mirror-0, or the path of the device.
+	Name string
+	// Class is the allocation class of the vdev, e.g. VdevClassLog, or empty for the normal class.
+	Class string
+	// Parent is the name of the top-level vdev of a device, or empty for the top-level vdevs.
+	Parent        string
+	Size          uint64
+	Allocated     uint64
+	Free          uint64
+	Fragmentation uint64
+	Capacity      uint64
+	Health        string
+}
+
+// VdevStats returns the space usage of each vdev of the zpool, e.g. to detect unbalanced vdevs.
+// The vdevs are returned in the order listed by ZFS, each top-level vdev followed by its devices.
+func (z *Zpool) VdevStats() ([]VdevStat, error) {
+	out, err := z.z.zpoolOutput("list", "-Hpv", z.Name)
+	if err != nil {
+		return nil, err
+	}
+	return parseVdevStats(out)
+}
+
+// CanReceive returns an error wrapping ErrNotSupported if the zpool cannot receive a stream sent with the options,
+// because a feature required by the stream is not enabled on the zpool, e.g. large_blocks for LargeBlocks,
+// which otherwise makes the receive fail with a cryptic error.
+func (z *Zpool) CanReceive(opts SendOptions) error {
+	var required []string
+	if opts.LargeBlocks {
+		required = append(required, "large_blocks")
+	}
+	if opts.EmbedData {
+		required = append(required, "embedded_data")
+	}
+	if opts.Raw {
+		required = append(required, "encryption")
+	}
+	if len(required) == 0 {
+		return nil
+	}
+	features, err := z.Features()
+	if err != nil {
+		return err
+	}
+	for _, f := range required {
+		if s := features[f]; s != FeatureEnabled && s != FeatureActive {
+			return fmt.Errorf("receiving into zpool %s: feature %s: %w", z.Name, f, ErrNotSupported)
+		}
+	}
+	return nil
+}
+
 // Datasets returns a slice of all ZFS datasets in a zpool.
 func (z *Zpool) Datasets() ([]*Dataset, error) {
 	return z.z.Datasets(z.Name)
@@ -69,12 +257,46 @@ func (z *Zpool) Snapshots() ([]*Dataset, error) {
 	return z.z.Snapshots(z.Name)
 }
 
+// PoolInventory are the datasets of a zpool, grouped by type.
+type PoolInventory struct {
+	Filesystems []*Dataset
+	Volumes     []*Dataset
+	Snapshots   []*Dataset
+	Bookmarks   []*Dataset
+}
+
+// Inventory returns all the datasets of the zpool grouped by type, listed with a single command.
+func (z *Zpool) Inventory() (PoolInventory, error) {
+	var inv PoolInventory
+	err := z.z.EachDataset(z.Name, func(ds *Dataset) error {
+		switch ds.Type {
+		case DatasetFilesystem:
+			inv.Filesystems = append(inv.Filesystems, ds)
+		case DatasetVolume:
+			inv.Volumes = append(inv.Volumes, ds)
+		case DatasetSnapshot:
+			inv.Snapshots = append(inv.Snapshots, ds)
+		case DatasetBookmark:
+			inv.Bookmarks = append(inv.Bookmarks, ds)
+		}
+		return nil
+	})
+	if err != nil {
+		return PoolInventory{}, err
+	}
+	return inv, nil
+}
+
 // CreateZpool creates a new ZFS zpool with the specified name, properties, and optional arguments.
+// An error will be returned if the name is not a valid zpool name, see ValidPoolName.
 //
 // A full list of available ZFS properties and command-line arguments may be found in the ZFS manual:
 // https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
 // https://openzfs.github.io/openzfs-docs/man/8/zpool-create.8.html
 func (z *zfs) CreateZpool(name string, properties map[string]string, args ...string) (*Zpool, error) {
+	if err := ValidPoolName(name); err != nil {
+		return nil, err
+	}
 	cli := make([]string, 1, 4)
 	cli[0] = "create"
 	if properties != nil {
@@ -89,6 +311,391 @@ func (z *zfs) CreateZpool(name string, properties map[string]string, args ...str
 	return &Zpool{z: z, Name: name}, nil
 }
 
+// Virtual device types which may be used in a VdevSpec.
+const (
+	VdevStripe = ""
+	VdevMirror = "mirror"
+	VdevRaidz  = "raidz"
+	VdevRaidz1 = "raidz1"
+	VdevRaidz2 = "raidz2"
+	VdevRaidz3 = "raidz3"
+)
+
+// vdevMinDevices is the minimum number of devices required by each virtual device type.
+var vdevMinDevices = map[string]int{
+	VdevStripe: 1,
+	VdevMirror: 2,
+	VdevRaidz:  2,
+	VdevRaidz1: 2,
+	VdevRaidz2: 3,
+	VdevRaidz3: 4,
+}
+
+// Virtual device allocation classes which may be used in a VdevSpec.
+// An empty class denotes a regular data virtual device.
+const (
+	VdevClassSpecial = "special"
+	VdevClassDedup   = "dedup"
+	VdevClassLog     = "log"
+	VdevClassCache   = "cache"
+	VdevClassSpare   = "spare"
+)
+
+// vdevClasses lists the allocation classes in the order they are given to zpool,
+// along with the virtual device types each of them supports (nil meaning any type).
+var vdevClasses = []struct {
+	class string
+	types []string
+}{
+	{class: ""},
+	{class: VdevClassSpecial},
+	{class: VdevClassDedup},
+	{class: VdevClassLog, types: []string{VdevStripe, VdevMirror}},
+	{class: VdevClassCache, types: []string{VdevStripe}},
+	{class: VdevClassSpare, types: []string{VdevStripe}},
+}
+
+// VdevSpec describes a virtual device of a zpool.
+// A VdevStripe spec adds each of its devices as a separate top-level virtual device.
+// The Class may be set to add a separate intent log (VdevClassLog), a cache device (VdevClassCache),
+// a hot spare (VdevClassSpare) or a special allocation class device (VdevClassSpecial, VdevClassDedup).
+//
+// More information about virtual devices may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zpoolconcepts.7.html#Virtual_Devices_(vdevs)
+type VdevSpec struct {
+	Class   string
+	Type    string
+	Devices []string
+}
+
+// args returns the zpool command line arguments describing the virtual device, without its class.
+func (v VdevSpec) args() ([]string, error) {
+	min, ok := vdevMinDevices[v.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown vdev type: %q", v.Type)
+	}
+	if len(v.Devices) < min {
+		return nil, fmt.Errorf("%s vdev requires at least %d devices, got %d", v.vdevType(), min, len(v.Devices))
+	}
+	var args []string
+	if v.Type != VdevStripe {
+		args = append(args, v.Type)
+	}
+	return append(args, v.Devices...), nil
+}
+
+func (v VdevSpec) vdevType() string {
+	if v.Type == VdevStripe {
+		return "stripe"
+	}
+	return v.Type
+}
+
+// vdevsArgs returns the zpool command line arguments describing the virtual devices.
+// The virtual devices are grouped by allocation class, each group being introduced by its class keyword.
+func vdevsArgs(vdevs []VdevSpec) ([]string, error) {
+	if len(vdevs) == 0 {
+		return nil, errors.New("at least one vdev is required")
+	}
+	for _, v := range vdevs {
+		if !isVdevClass(v.Class) {
+			return nil, fmt.Errorf("unknown vdev class: %q", v.Class)
+		}
+	}
+	var args []string
+	for _, c := range vdevClasses {
+		keyword := false
+		for _, v := range vdevs {
+			if v.Class != c.class {
+				continue
+			}
+			if c.types != nil && !contains(c.types, v.Type) {
+				return nil, fmt.Errorf("%s vdev cannot be of type %s", c.class, v.vdevType())
+			}
+			a, err := v.args()
+			if err != nil {
+				return nil, err
+			}
+			if c.class != "" && !keyword {
+				args = append(args, c.class)
+				keyword = true
+			}
+			args = append(args, a...)
+		}
+	}
+	return args, nil
+}
+
+func isVdevClass(class string) bool {
+	for _, c := range vdevClasses {
+		if c.class == class {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateZpoolOptions are the options which may be passed to CreateZpoolWithVdevs.
+type CreateZpoolOptions struct {
+	// Force the use of devices, even if they appear in use (zpool create -f).
+	Force bool
+	// Mountpoint of the root dataset (zpool create -m).
+	Mountpoint string
+	// Altroot is the alternate root directory of the zpool (zpool create -R).
+	Altroot string
+	// DryRun only validates the configuration without creating the zpool (zpool create -n),
+	// in which case CreateZpoolWithVdevs returns a nil zpool. PlanZpool returns the layout which would be created.
+	DryRun bool
+}
+
+// CreateZpoolWithVdevs creates a new ZFS zpool with the specified name, properties and virtual devices.
+// It is the typed alternative of CreateZpool, which validates the name and the virtual devices before running the command.
+//
+// A full list of available ZFS properties and command-line arguments may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/7/zfsprops.7.html.
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-create.8.html
+func (z *zfs) CreateZpoolWithVdevs(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) (*Zpool, error) {
+	cli, err := createZpoolArgs(name, properties, vdevs, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := z.zpool(cli...); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return nil, nil
+	}
+
+	return &Zpool{z: z, Name: name}, nil
+}
+
+// PlanZpool validates the zpool configuration like CreateZpoolWithVdevs with the DryRun option,
+// and returns the virtual devices layout which would be created, as reported by zpool create -n,
+// e.g. to catch an accidental non-redundant layout before creating the zpool.
+// The stripe virtual devices are returned as one VdevSpec per device.
+func (z *zfs) PlanZpool(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) ([]VdevSpec, error) {
+	opts.DryRun = true
+	cli, err := createZpoolArgs(name, properties, vdevs, opts)
+	if err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	if _, err := z.run(nil, &stdout, "zpool", cli...); err != nil {
+		return nil, err
+	}
+	return parseZpoolLayout(stdout.String())
+}
+
+// createZpoolArgs returns the zpool create command line arguments.
+func createZpoolArgs(name string, properties map[string]string, vdevs []VdevSpec, opts CreateZpoolOptions) ([]string, error) {
+	if err := ValidPoolName(name); err != nil {
+		return nil, err
+	}
+	devs, err := vdevsArgs(vdevs)
+	if err != nil {
+		return nil, err
+	}
+	cli := make([]string, 1, 8)
+	cli[0] = "create"
+	if opts.DryRun {
+		cli = append(cli, "-n")
+	}
+	if opts.Force {
+		cli = append(cli, "-f")
+	}
+	if opts.Mountpoint != "" {
+		cli = append(cli, "-m", opts.Mountpoint)
+	}
+	if opts.Altroot != "" {
+		cli = append(cli, "-R", opts.Altroot)
+	}
+	if properties != nil {
+		cli = append(cli, propsSlice(properties)...)
+	}
+	cli = append(cli, name)
+	return append(cli, devs...), nil
+}
+
+// layoutClasses maps the allocation class headers of the zpool create -n output to the virtual device classes.
+var layoutClasses = map[string]string{
+	"special": VdevClassSpecial,
+	"dedup":   VdevClassDedup,
+	"logs":    VdevClassLog,
+	"cache":   VdevClassCache,
+	"spares":  VdevClassSpare,
+}
+
+// layoutVdevType matches the grouping virtual devices of the zpool create -n output, which may be numbered, e.g. mirror-0.
+var layoutVdevType = regexp.MustCompile(`^(mirror|raidz[123]?)(-[0-9]+)?$`)
+
+// parseZpoolLayout parses the layout printed by zpool create -n, where the nesting is given by the indentation:
+// the zpool name and the allocation class headers come first, then the top-level virtual devices and their devices.
+func parseZpoolLayout(out string) ([]VdevSpec, error) {
+	var (
+		vdevs     []VdevSpec
+		class     string
+		base, top = -1, -1
+		group     *VdevSpec
+	)
+	for _, l := range strings.Split(out, "\n") {
+		name := strings.TrimSpace(l)
+		if name == "" || strings.HasPrefix(name, "would create") {
+			continue
+		}
+		indent := len(l) - len(strings.TrimLeft(l, " \t"))
+		switch {
+		case base < 0 || indent <= base:
+			// the zpool name, then the allocation classes headers
+			c, ok := layoutClasses[name]
+			if base >= 0 && !ok {
+				return nil, fmt.Errorf("unknown vdev class in zpool layout: %q", name)
+			}
+			base, top, class, group = indent, -1, c, nil
+		case top < 0 || indent <= top:
+			top = indent
+			if m := layoutVdevType.FindStringSubmatch(name); m != nil {
+				vdevs = append(vdevs, VdevSpec{Class: class, Type: m[1]})
+				group = &vdevs[len(vdevs)-1]
+				continue
+			}
+			vdevs = append(vdevs, VdevSpec{Class: class, Type: VdevStripe, Devices: []string{name}})
+			group = nil
+		default:
+			if group == nil {
+				return nil, fmt.Errorf("unexpected device in zpool layout: %q", name)
+			}
+			group.Devices = append(group.Devices, name)
+		}
+	}
+	if len(vdevs) == 0 {
+		return nil, errors.New("output does not match what is expected on this platform")
+	}
+	return vdevs, nil
+}
+
+// LabelClear removes the ZFS label information from the device, e.g. to reuse a disk of a destroyed zpool.
+// If force is set, the label is cleared even if the device appears to be part of an exported or foreign zpool (zpool labelclear -f).
+//
+// More information may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-labelclear.8.html
+func (z *zfs) LabelClear(device string, force bool) error {
+	if device == "" {
+		return errors.New("device must not be empty")
+	}
+	args := []string{"labelclear"}
+	if force {
+		args = append(args, "-f")
+	}
+	return z.zpool(append(args, device)...)
+}
+
+// Add adds the virtual devices to the zpool, e.g. to grow it or to add log, cache or spare devices.
+// If force is set, the devices are used even if they appear in use or have a mismatched replication level (zpool add -f).
+//
+// More information may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-add.8.html
+func (z *Zpool) Add(vdevs []VdevSpec, force bool) error {
+	devs, err := vdevsArgs(vdevs)
+	if err != nil {
+		return err
+	}
+	args := make([]string, 1, 3+len(devs))
+	args[0] = "add"
+	if force {
+		args = append(args, "-f")
+	}
+	args = append(args, z.Name)
+	args = append(args, devs...)
+	return z.z.zpool(args...)
+}
+
+// SplitOptions are the options which may be passed to Split.
+type SplitOptions struct {
+	// Devices are the devices to detach into the new zpool, one per mirror.
+	// By default, the last device of each mirror is used.
+	Devices []string
+	// Properties are set on the new zpool (zpool split -o).
+	Properties map[string]string
+	// Altroot imports the new zpool using the alternate root directory (zpool split -R).
+	Altroot string
+}
+
+// Split detaches one device from each mirror of the zpool to create a new zpool with the given name,
+// which holds a copy of the data at the time of the split, e.g. to take it offsite as a backup.
+// The new zpool is left exported unless an Altroot is set: in that case it is imported and fully retrieved,
+// otherwise the returned zpool only has its name set and must be imported before use.
+// All the top-level vdevs of the zpool must be mirrors.
+//
+// More information may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-split.8.html
+func (z *Zpool) Split(newName string, opts SplitOptions) (*Zpool, error) {
+	args := []string{"split"}
+	if opts.Properties != nil {
+		args = append(args, propsSlice(opts.Properties)...)
+	}
+	if opts.Altroot != "" {
+		args = append(args, "-R", opts.Altroot)
+	}
+	args = append(args, z.Name, newName)
+	args = append(args, opts.Devices...)
+	if err := z.z.zpool(args...); err != nil {
+		return nil, err
+	}
+	if opts.Altroot == "" {
+		return &Zpool{z: z.z, Name: newName}, nil
+	}
+	return z.z.GetZpool(newName)
+}
+
+// Reguid generates a new unique identifier for the zpool and refreshes the receiving zpool,
+// e.g. so that a copy of a zpool, made by a split or a block level copy of its devices, can be imported alongside the original.
+//
+// More information may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-reguid.8.html
+func (z *Zpool) Reguid() error {
+	if err := z.z.zpool("reguid", z.Name); err != nil {
+		return err
+	}
+	return z.Refresh()
+}
+
+// Rename renames the zpool, which ZFS cannot do in place, by exporting it and importing it under the new name,
+// and returns the renamed zpool. The datasets of the zpool are unmounted while it is exported.
+// The devices of the zpool are searched in the given directories, e.g. for file devices, or in /dev by default.
+// If the import fails, the zpool is left exported and the returned error tells how to import it back.
+//
+// More information may be found in the ZFS manual:
+// https://openzfs.github.io/openzfs-docs/man/8/zpool-import.8.html
+func (z *Zpool) Rename(newName string, searchDirs ...string) (*Zpool, error) {
+	if newName == z.Name {
+		return nil, fmt.Errorf("invalid new name %q for zpool %s", newName, z.Name)
+	}
+	if err := ValidPoolName(newName); err != nil {
+		return nil, err
+	}
+	if err := z.z.zpool("export", z.Name); err != nil {
+		return nil, err
+	}
+	args := []string{"import"}
+	for _, d := range searchDirs {
+		args = append(args, "-d", d)
+	}
+	args = append(args, z.Name, newName)
+	if err := z.z.zpool(args...); err != nil {
+		return nil, fmt.Errorf("zpool %s is left exported, it must be imported back with zpool import: %w", z.Name, err)
+	}
+	return z.z.GetZpool(newName)
+}
+
 // Destroy destroys a ZFS zpool by name.
 func (z *Zpool) Destroy() error {
 	err := z.z.zpool("destroy", z.Name)
@@ -0,0 +1,194 @@
+package zfs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestZpoolProperties(t *testing.T) {
+	e := &recordExec{stdout: "test\tcomment\thello world\tlocal\n"}
+	pool := &Zpool{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test"}
+
+	v, err := pool.GetProperty("comment")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"get", "-Hp", "comment", "test"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	if v != "hello world" {
+		t.Fatalf("wanted: %q, got: %q", "hello world", v)
+	}
+
+	e.stdout = "test\tcomment\thello world\tlocal\ntest\tfeature@async_destroy\tenabled\tlocal\n"
+	props, err := pool.GetAllProperties()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := map[string]string{"comment": "hello world", "feature@async_destroy": "enabled"}; !reflect.DeepEqual(want, props) {
+		t.Fatalf("wanted: %v, got: %v", want, props)
+	}
+
+	e.stdout = ""
+	if err := pool.SetProperty("autoexpand", "on"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"set", "autoexpand=on", "test"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	if !pool.Autoexpand {
+		t.Fatal("the autoexpand field should have been updated")
+	}
+	if v, _ := pool.GetProperty("autoexpand"); v != "on" {
+		t.Fatalf("wanted the value to be cached, got: %q", v)
+	}
+}
+
+func TestLabelClear(t *testing.T) {
+	r := &recordExec{}
+	z := &zfs{exec: r, logger: &defaultLogger{}}
+	if err := z.LabelClear("/dev/sdb", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"labelclear", "-f", "/dev/sdb"}; r.cmd != "zpool" || !reflect.DeepEqual(want, r.args) {
+		t.Fatalf("wanted: zpool %v, got: %s %v", want, r.cmd, r.args)
+	}
+	if err := z.LabelClear("", false); err == nil {
+		t.Fatal("expected error for an empty device")
+	}
+}
+
+func TestPlanZpool(t *testing.T) {
+	e := &recordExec{stdout: "would create 'tank' with the following layout:\n\n" +
+		"\ttank\n" +
+		"\t  mirror\n" +
+		"\t    /dev/sda\n" +
+		"\t    /dev/sdb\n" +
+		"\t  /dev/sdc\n" +
+		"\tlogs\n" +
+		"\t  mirror-1\n" +
+		"\t    /dev/nvme0n1\n" +
+		"\t    /dev/nvme1n1\n" +
+		"\tcache\n" +
+		"\t  /dev/nvme2n1\n"}
+	z := &zfs{exec: e, logger: &defaultLogger{}}
+
+	vdevs := []VdevSpec{
+		{Type: VdevMirror, Devices: []string{"/dev/sda", "/dev/sdb"}},
+		{Type: VdevStripe, Devices: []string{"/dev/sdc"}},
+		{Class: VdevClassLog, Type: VdevMirror, Devices: []string{"/dev/nvme0n1", "/dev/nvme1n1"}},
+		{Class: VdevClassCache, Type: VdevStripe, Devices: []string{"/dev/nvme2n1"}},
+	}
+	layout, err := z.PlanZpool("tank", nil, vdevs, CreateZpoolOptions{Force: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"create", "-n", "-f", "tank", "mirror", "/dev/sda", "/dev/sdb", "/dev/sdc", "log", "mirror", "/dev/nvme0n1", "/dev/nvme1n1", "cache", "/dev/nvme2n1"}
+	if !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	if !reflect.DeepEqual(vdevs, layout) {
+		t.Fatalf("wanted: %+v, got: %+v", vdevs, layout)
+	}
+
+	pool, err := z.CreateZpoolWithVdevs("tank", nil, vdevs, CreateZpoolOptions{DryRun: true})
+	if err != nil || pool != nil {
+		t.Fatalf("wanted no zpool and no error, got: %v, %v", pool, err)
+	}
+	if !readOnly("zpool", e.args) {
+		t.Fatalf("the dry run should be read only: %v", e.args)
+	}
+}
+
+func TestZpoolFeatures(t *testing.T) {
+	e := &recordExec{stdout: "size\t1024\n" +
+		"feature@async_destroy\tenabled\n" +
+		"feature@large_blocks\tactive\n" +
+		"feature@encryption\tdisabled\n"}
+	pool := &Zpool{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test"}
+
+	features, err := pool.Features()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"get", "-Hp", "-o", "property,value", "all", "test"}; !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	want := map[string]string{
+		"async_destroy": FeatureEnabled,
+		"large_blocks":  FeatureActive,
+		"encryption":    FeatureDisabled,
+	}
+	if !reflect.DeepEqual(want, features) {
+		t.Fatalf("wanted: %v, got: %v", want, features)
+	}
+	flags, err := pool.FeatureFlags()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(want, flags) {
+		t.Fatalf("wanted: %v, got: %v", want, flags)
+	}
+	if err := pool.CanReceive(SendOptions{Raw: true}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("wanted: %v, got: %v", ErrNotSupported, err)
+	}
+	if err := pool.CanReceive(SendOptions{LargeBlocks: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestZpoolRenameImportFailure(t *testing.T) {
+	z := &zfs{exec: &recordExec{fail: "import", err: errors.New("exit status 1")}, logger: &defaultLogger{}}
+	pool := &Zpool{z: z, Name: "test"}
+	_, err := pool.Rename("renamed", "/tmp/devices")
+	var zErr *Error
+	if !errors.As(err, &zErr) || zErr.Debug != "zpool import -d /tmp/devices test renamed" {
+		t.Fatalf("wanted the import error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "left exported") {
+		t.Fatalf("wanted the error to tell that the zpool is exported, got: %v", err)
+	}
+}
+
+func TestZpoolInventory(t *testing.T) {
+	withType := func(line, typ string) string {
+		return strings.Replace(line, "\t"+DatasetFilesystem+"\t", "\t"+typ+"\t", 1)
+	}
+	e := &recordExec{stdout: testDatasetLine("test", "/test") +
+		testDatasetLine("test/fs", "/test/fs") +
+		withType(testDatasetLine("test/vol", "-"), DatasetVolume) +
+		withType(testDatasetLine("test/fs@a", "-"), DatasetSnapshot) +
+		withType(testDatasetLine("test/fs@b", "-"), DatasetSnapshot) +
+		withType(testDatasetLine("test/fs#a", "-"), DatasetBookmark)}
+	pool := &Zpool{z: &zfs{exec: e, logger: &defaultLogger{}}, Name: "test"}
+
+	inv, err := pool.Inventory()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := append(ListOptions{}.args(), "test"); !reflect.DeepEqual(want, e.args) {
+		t.Fatalf("wanted: %v, got: %v", want, e.args)
+	}
+	names := func(datasets []*Dataset) []string {
+		var n []string
+		for _, ds := range datasets {
+			n = append(n, ds.Name)
+		}
+		return n
+	}
+	for typ, test := range map[string]struct {
+		got  []*Dataset
+		want []string
+	}{
+		DatasetFilesystem: {inv.Filesystems, []string{"test", "test/fs"}},
+		DatasetVolume:     {inv.Volumes, []string{"test/vol"}},
+		DatasetSnapshot:   {inv.Snapshots, []string{"test/fs@a", "test/fs@b"}},
+		DatasetBookmark:   {inv.Bookmarks, []string{"test/fs#a"}},
+	} {
+		if got := names(test.got); !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("%s: wanted: %v, got: %v", typ, test.want, got)
+		}
+	}
+}